@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/analysis"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newAliasesCommand() *cobra.Command {
+	opts := &buildOptions{}
+	aliasOptions := analysis.DefaultAliasOptions
+	var format string
+	var pairs bool
+
+	cmd := &cobra.Command{
+		Use:   "aliases",
+		Short: "Propose the near addresses belonging to the same router",
+		Long: `Pairs the near addresses forwarding their common prefixes to the same next
+hops, having mostly the same next hops, or being the other end of the /30 or
+/31 subnet of a next hop of the other, and joins the pairs into alias sets.
+The text format writes the sets in the format of the --aliases flag.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var exportFormat ds.ExportFormat
+			if format != "text" || pairs {
+				var err error
+				if exportFormat, err = ds.ParseExportFormat(format); err != nil {
+					return err
+				}
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			hints := analysis.FindAliasHints(f, aliasOptions)
+			sets, evidence := analysis.GroupAliases(hints)
+			log.Printf("Found %v alias pairs in %v sets.\n", len(hints), sets.Len())
+			switch {
+			case pairs:
+				return analysis.WriteAliasHints(cmd.OutOrStdout(), hints, exportFormat)
+			case format == "text":
+				return sets.Write(cmd.OutOrStdout())
+			default:
+				return analysis.WriteAliasSets(cmd.OutOrStdout(), sets, evidence, exportFormat)
+			}
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&format, "format", "f", "csv", "output format: csv, tsv, json or text")
+	flags.BoolVar(&pairs, "pairs", false, "write the pairs of addresses with their evidence instead of the sets")
+	flags.Float64Var(&aliasOptions.MinSimilarity, "min-similarity", aliasOptions.MinSimilarity, "smallest share of the common prefixes with the same next hops, and Jaccard index of the next hops, of a pair")
+	flags.IntVar(&aliasOptions.MinShared, "min-shared", aliasOptions.MinShared, "smallest number of common prefixes with the same next hops, or of common next hops, of a pair")
+	flags.IntVar(&aliasOptions.MaxGroupSize, "max-group-size", aliasOptions.MaxGroupSize, "ignore the prefixes and next hops shared by more routers")
+	return cmd
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/analysis"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newBordersCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format, asTable, ixpPrefixes string
+	var keepThirdParty bool
+
+	cmd := &cobra.Command{
+		Use:   "borders",
+		Short: "Infer the interdomain links of the FIB",
+		Long: `Reports the near and far address pairs whose origin ASes differ, with the
+destination prefixes routed over them. A far address in an IXP peering LAN is
+given the AS its own next hops are mostly in. A far address whose next hops are
+mostly in the AS of the near router is likely a third-party address, these
+links are left out unless asked.
+
+The --as-table file is a CAIDA pfx2as file or a RIB converted to text with
+bgpdump -m, binary MRT dumps are not read.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if asTable == "" {
+				return errors.New("the borders need an AS table, see --as-table")
+			}
+			exportFormat, err := ds.ParseExportFormat(format)
+			if err != nil {
+				return err
+			}
+			annotator, err := loadASTable(asTable)
+			if err != nil {
+				return err
+			}
+			var ixps *analysis.IXPTable
+			if ixpPrefixes != "" {
+				if ixps, err = analysis.LoadIXPFile(ixpPrefixes); err != nil {
+					return fmt.Errorf("loading IXP prefixes %v: %w", ixpPrefixes, err)
+				}
+				log.Printf("Loaded IXP prefixes, prefixes=%v.\n", ixps.Len())
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			links := analysis.InferBorders(f, analysis.BorderOptions{AS: annotator, IXPs: ixps, KeepThirdParty: keepThirdParty})
+			log.Printf("Inferred %v interdomain links.\n", len(links))
+			return analysis.WriteBorders(cmd.OutOrStdout(), links, exportFormat)
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&format, "format", "f", "csv", "output format: csv, tsv or json")
+	flags.StringVar(&asTable, "as-table", "", "pfx2as file or bgpdump -m text RIB giving the origin ASes, not a binary MRT dump")
+	flags.StringVar(&ixpPrefixes, "ixp-prefixes", "", "file of IXP peering LAN prefixes, one per line optionally followed by the IXP name")
+	flags.BoolVar(&keepThirdParty, "keep-third-party", false, "also report the links whose far address is likely a third-party address")
+	return cmd
+}
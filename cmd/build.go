@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/asn"
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/nfp"
+)
+
+// buildOptions holds every knob used while building a FIB from NFP records.
+type buildOptions struct {
+	snapshot         string
+	inputs           []string
+	ipv4PrefixLength int
+	ipv6PrefixLength int
+	prefixLength     int
+	total            int
+	limit            int
+	bufferSize       int
+	initialSize      uint
+	optimizeIPv4     bool
+	progressPeriod   int
+	parseWorkers     int
+	insertWorkers    int
+	inputFormat      string
+	parseReport      string
+	aggregate        bool
+	aliases          string
+	aliasFar         bool
+	aliasConflicts   string
+}
+
+// Registers the build flags on the given command, they are checked before it
+// runs.
+func (o *buildOptions) addFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringSliceVarP(&o.inputs, "input", "i", []string{"-"}, "NFP records files or globs, read in order and decompressed if needed, - for stdin")
+	flags.StringVar(&o.inputFormat, "input-format", "auto", "format of the NFP records: auto, csv, TabSeparatedWithNames or JSONEachRow")
+	flags.StringVar(&o.parseReport, "parse-report", "", "write the JSON report of the skipped lines to this file")
+	flags.IntVar(&o.ipv4PrefixLength, "ipv4-prefix-length", 24, "prefix length of the IPv4 destination networks")
+	flags.IntVar(&o.ipv6PrefixLength, "ipv6-prefix-length", 48, "prefix length of the IPv6 destination networks")
+	flags.IntVar(&o.prefixLength, "prefix-length", 24, "prefix length of the IPv4 destination networks")
+	flags.MarkDeprecated("prefix-length", "use --ipv4-prefix-length instead")
+	flags.BoolVar(&o.aggregate, "aggregate", false, "merge the sibling prefixes with the same next hops into their supernet")
+	flags.StringVar(&o.aliases, "aliases", "", "merge the tables of the interfaces of the routers of this ITDK style alias file")
+	flags.BoolVar(&o.aliasFar, "alias-far", false, "also replace the far addresses by their router when merging the aliases")
+	flags.StringVar(&o.aliasConflicts, "alias-conflicts", "", "write the prefixes the interfaces of a router forward differently to this CSV file")
+	flags.IntVar(&o.total, "total", 0, "expected number of records, used for the progress estimation")
+	flags.IntVar(&o.limit, "limit", -1, "maximum number of records to read, -1 for all")
+	flags.IntVar(&o.bufferSize, "buffer-size", 100, "size of the batch channel buffers")
+	flags.UintVar(&o.initialSize, "initial-size", 1000, "initial number of routers allocated in the FIB")
+	flags.BoolVar(&o.optimizeIPv4, "optimize-ipv4", true, "optimize the tables for IPv4")
+	flags.IntVar(&o.progressPeriod, "progress-period", 10000, "number of records between progress logs, 0 to disable")
+	flags.IntVar(&o.parseWorkers, "parse-workers", runtime.NumCPU(), "number of goroutines parsing the records")
+	flags.IntVar(&o.insertWorkers, "insert-workers", runtime.NumCPU(), "number of goroutines inserting into the FIB, one shard each")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return o.validate(cmd)
+	}
+}
+
+// Applies the deprecated --prefix-length unless --ipv4-prefix-length is given
+// too, and checks the prefix lengths and the limit.
+func (o *buildOptions) validate(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if flags.Changed("prefix-length") && !flags.Changed("ipv4-prefix-length") {
+		o.ipv4PrefixLength = o.prefixLength
+	}
+	if o.ipv4PrefixLength < 0 || o.ipv4PrefixLength > 32 {
+		return fmt.Errorf("invalid IPv4 prefix length %v, it must be between 0 and 32", o.ipv4PrefixLength)
+	}
+	if o.ipv6PrefixLength < 0 || o.ipv6PrefixLength > 128 {
+		return fmt.Errorf("invalid IPv6 prefix length %v, it must be between 0 and 128", o.ipv6PrefixLength)
+	}
+	if o.limit < -1 {
+		return fmt.Errorf("invalid limit %v, it must be -1 for all the records or at least 0", o.limit)
+	}
+	return nil
+}
+
+// Registers the build flags and the snapshot flag for the commands that can
+// load a FIB instead of building it.
+func (o *buildOptions) addLoadFlags(cmd *cobra.Command) {
+	o.addFlags(cmd)
+	cmd.Flags().StringVarP(&o.snapshot, "snapshot", "s", "", "load the FIB from this snapshot instead of building it")
+}
+
+// Loads the FIB from the snapshot if one is given, otherwise builds it.
+func (o *buildOptions) load() (*ds.FIB, error) {
+	if o.snapshot == "" {
+		f, _, err := o.build()
+		return f, err
+	}
+
+	f, _, err := loadSnapshot(o.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if f, err = o.mergeAliases(f); err != nil {
+		return nil, err
+	}
+	o.aggregateFIB(f)
+	return f, nil
+}
+
+// Loads the FIB and the header of a snapshot file.
+func loadSnapshot(path string) (*ds.FIB, *ds.SnapshotHeader, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+
+	log.Printf("Loading snapshot %v.\n", path)
+	f, header, err := ds.Load(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading snapshot %v: %w", path, err)
+	}
+	log.Printf("Loaded snapshot of %v, records=%v, prefixlength=%v/%v, created=%v.\n",
+		header.Source, header.Records, header.IPv4PrefixLength, header.IPv6PrefixLength, header.Created)
+	return f, header, nil
+}
+
+// Merges the tables of the aliased routers if an alias file is given.
+func (o *buildOptions) mergeAliases(f *ds.FIB) (*ds.FIB, error) {
+	if o.aliases == "" {
+		return f, nil
+	}
+	in, _, err := nfp.Open(o.aliases)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	aliases, duplicates, err := ds.LoadAliases(in)
+	if err != nil {
+		return nil, fmt.Errorf("loading aliases %v: %w", o.aliases, err)
+	}
+	if duplicates > 0 {
+		log.Printf("Skipped %v addresses of %v already in a previous alias set.\n", duplicates, o.aliases)
+	}
+	merged, conflicts := f.CollapseAliases(aliases, ds.CollapseOptions{MapFarAddresses: o.aliasFar})
+	log.Printf("Merged the aliases of %v routers, routers=%v/%v, conflicts=%v.\n", aliases.Len(), merged.Len(), f.Len(), len(conflicts))
+	if o.aliasConflicts == "" {
+		return merged, nil
+	}
+
+	out, err := os.Create(o.aliasConflicts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ds.WriteAliasConflicts(out, conflicts, ds.ExportCSV); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return merged, out.Close()
+}
+
+// Aggregates the prefixes of the FIB if asked.
+func (o *buildOptions) aggregateFIB(f *ds.FIB) {
+	if !o.aggregate {
+		return
+	}
+	log.Printf("Aggregating the prefixes.\n")
+	log.Printf("Done aggregating, %v.\n", f.Aggregate())
+}
+
+// Reads the NFP records from the configured input and builds the FIB. It also
+// returns the number of records read.
+func (o *buildOptions) build() (*ds.FIB, uint64, error) {
+	format, err := nfp.ParseFormat(o.inputFormat)
+	if err != nil {
+		return nil, 0, err
+	}
+	paths, err := nfp.ExpandPaths(o.inputs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Printf("Starting to process %v NFP files, prefixlength=%v/%v, total=%v.\n", len(paths), o.ipv4PrefixLength, o.ipv6PrefixLength, o.total)
+
+	reader := nfp.NewMultiReader(paths, format)
+	defer reader.Close()
+	var f *ds.FIB
+	var report *nfp.Report
+	if o.parseWorkers > 1 || o.insertWorkers > 1 {
+		f, report, err = o.buildParallel(reader)
+	} else {
+		f, report, err = o.buildSequential(reader)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	log.Printf("Done processing, %v.\n", report)
+	if err := o.writeReport(report); err != nil {
+		return nil, 0, err
+	}
+	if f, err = o.mergeAliases(f); err != nil {
+		return nil, 0, err
+	}
+	o.aggregateFIB(f)
+	return f, uint64(report.Records), nil
+}
+
+// Reads the records one by one and inserts them into the FIB.
+func (o *buildOptions) buildSequential(reader *nfp.MultiReader) (*ds.FIB, *nfp.Report, error) {
+	f := ds.NewFIB(o.initialSize, o.optimizeIPv4, uint(o.ipv4PrefixLength), uint(o.ipv6PrefixLength))
+	progress := newProgress(o.total, o.progressPeriod)
+
+	for i := 0; i < o.limit || o.limit == -1; i++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		progress.add(1)
+
+		destinationNetwork, ok := o.probeDstPrefix(&record, reader.Report())
+		if !ok {
+			continue
+		}
+		f.InsertObservation(record.NearAddr, destinationNetwork, record.FarAddr, observationOf(&record))
+	}
+
+	return f, reader.Report(), nil
+}
+
+// Returns the destination network of the record. The records whose network
+// cannot be computed are moved from the records to the errors of the report.
+func (o *buildOptions) probeDstPrefix(record *nfp.Record, report *nfp.Report) (netip.Prefix, bool) {
+	prefix, err := record.ProbeDstPrefix(o.ipv4PrefixLength, o.ipv6PrefixLength)
+	if err != nil {
+		report.Records--
+		report.Add(&nfp.ParseError{
+			File:   record.File,
+			Line:   record.Line,
+			Column: "probe_dst_addr",
+			Value:  record.ProbeDstAddr.String(),
+			Err:    err,
+		})
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
+// Returns the observation of the record, with its capture time and round if
+// the input has them.
+func observationOf(record *nfp.Record) ds.Observation {
+	var timestamp time.Time
+	var round uint32
+	if record.Has(nfp.FieldTimestamp) {
+		timestamp = record.Timestamp
+	}
+	if record.Has(nfp.FieldRound) {
+		round = record.Round
+	}
+	return ds.NewObservation(timestamp, round)
+}
+
+// Logs the parse errors and writes the report if asked.
+func (o *buildOptions) writeReport(report *nfp.Report) error {
+	for i, err := range report.Samples {
+		if i == 10 {
+			log.Printf("... and %v more parse errors.\n", report.Errors-i)
+			break
+		}
+		log.Printf("Skipped %v.\n", err)
+	}
+	if o.parseReport == "" {
+		return nil
+	}
+
+	out, err := os.Create(o.parseReport)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJSON(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// progress logs the number of processed records and a time estimation.
+type progress struct {
+	i         int
+	total     int
+	period    int
+	startTime time.Time
+}
+
+func newProgress(total int, period int) *progress {
+	return &progress{
+		total:     total,
+		period:    period,
+		startTime: time.Now(),
+	}
+}
+
+// Counts n records and logs the progress every period records.
+func (p *progress) add(n int) {
+	previous := p.i
+	p.i += n
+	if p.period <= 0 || (previous != 0 && previous/p.period == p.i/p.period) {
+		return
+	}
+
+	timePassedSeconds := time.Since(p.startTime).Seconds()
+	timePassed := time.Duration(timePassedSeconds * float64(time.Second)).Truncate(time.Second)
+
+	if p.total <= 0 {
+		log.Printf("Progress: %v %10v.\n", p.i, timePassed)
+		return
+	}
+
+	percent := 100 * float64(p.i) / float64(p.total)
+	totalTimeEstimateSeconds := (100 / percent) * timePassedSeconds
+	remeaningTimeEstimateSeconds := totalTimeEstimateSeconds - timePassedSeconds
+
+	totalEstimation := time.Duration(totalTimeEstimateSeconds * float64(time.Second)).Truncate(time.Second)
+	remeaning := time.Duration(remeaningTimeEstimateSeconds * float64(time.Second)).Truncate(time.Second)
+
+	log.Printf("Progress: %v/%v [%.2f%%] %10v %10v %10v.\n", p.i, p.total, percent, timePassed, remeaning, totalEstimation)
+}
+
+func newBuildCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format, output, source string
+	var sorted bool
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the FIB from NFP records and print or save it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, records, err := opts.build()
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				return writeFIB(cmd.OutOrStdout(), f, format, ds.ExportOptions{Sorted: sorted})
+			}
+
+			if source == "" {
+				source = strings.Join(opts.inputs, ",")
+			}
+			return saveSnapshot(output, f, &ds.SnapshotHeader{
+				Source:  source,
+				Records: records,
+			})
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().StringVarP(&format, "format", "f", "stats", "output format: stats, csv, tsv, json or text")
+	cmd.Flags().BoolVar(&sorted, "sorted", false, "write the routers ordered by their address")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "save the FIB as a snapshot to this file instead of printing it")
+	cmd.Flags().StringVar(&source, "source", "", "source dataset recorded in the snapshot header, defaults to the input")
+	return cmd
+}
+
+// Saves the FIB as a snapshot file.
+func saveSnapshot(path string, f *ds.FIB, header *ds.SnapshotHeader) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Save(out, header); err != nil {
+		out.Close()
+		return err
+	}
+	log.Printf("Saved snapshot to %v.\n", path)
+	return out.Close()
+}
+
+// Writes the FIB in the given format while walking it, the export format of
+// the options is set from the format.
+func writeFIB(w io.Writer, f *ds.FIB, format string, opts ds.ExportOptions) error {
+	switch format {
+	case "stats":
+		opts.Format = ds.ExportCSV
+		return f.WriteStats(w, opts)
+	case "text":
+		return f.WriteText(w, opts.Sorted)
+	default:
+		exportFormat, err := ds.ParseExportFormat(format)
+		if err != nil {
+			return err
+		}
+		opts.Format = exportFormat
+		return f.Export(w, opts)
+	}
+}
+
+// Loads the AS table if a path is given, otherwise returns nil.
+func loadASTable(path string) (ds.ASAnnotator, error) {
+	if path == "" {
+		return nil, nil
+	}
+	log.Printf("Loading AS table %v.\n", path)
+	table, skipped, err := asn.LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading AS table %v: %w", path, err)
+	}
+	log.Printf("Loaded AS table, prefixes=%v, skipped=%v.\n", table.Len(), skipped)
+	return table, nil
+}
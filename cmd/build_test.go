@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/nfp"
+)
+
+// Writes an export with a few bad lines and returns its path.
+func writeTestExport(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("near_addr,far_addr,probe_dst_addr,round,capture_timestamp\n")
+	for i := 0; i < 5000; i++ {
+		near := fmt.Sprintf("10.0.%v.%v", i%7, i%3)
+		far := fmt.Sprintf("10.1.%v.%v", i%5, i%4)
+		switch {
+		case i%500 == 17:
+			fmt.Fprintf(&buf, "%v,not-an-address,8.8.8.8,1,2024-01-01 00:00:00\n", near)
+		case i%3 == 0:
+			fmt.Fprintf(&buf, "%v,%v,2001:db8:%x::1,%v,2024-01-01 00:%02d:00\n", near, far, i%11, i%4+1, i%60)
+		default:
+			fmt.Fprintf(&buf, "%v,%v,%v.%v.%v.1,%v,2024-01-01 00:%02d:00\n", near, far, 1+i%13, i%17, i%19, i%4+1, i%60)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "records.csv")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSequentialAndParallelBuildsMatch(t *testing.T) {
+	path := writeTestExport(t)
+	opts := buildOptions{
+		ipv4PrefixLength: 24,
+		ipv6PrefixLength: 48,
+		limit:            -1,
+		bufferSize:       4,
+		initialSize:      16,
+		optimizeIPv4:     true,
+	}
+	exports, reports := buildBoth(t, opts, path)
+	if exports[0] == "" || exports[0] != exports[1] {
+		t.Errorf("the sequential and the parallel builds differ")
+	}
+
+	// Every third line probes one of eleven IPv6 networks.
+	ipv6Networks := make(map[string]bool)
+	for _, line := range strings.Split(exports[0], "\n") {
+		if fields := strings.Split(line, ","); len(fields) > 1 && strings.Contains(fields[1], ":") {
+			ipv6Networks[fields[1]] = true
+		}
+	}
+	if len(ipv6Networks) != 11 || !ipv6Networks[`"2001:db8:a::/48"`] {
+		t.Errorf("the builds have the IPv6 networks %v, want the eleven 2001:db8:x::/48", ipv6Networks)
+	}
+
+	if !reflect.DeepEqual(reports[0], reports[1]) {
+		t.Errorf("sequential report %+v, parallel report %+v", reports[0], reports[1])
+	}
+	if reports[0].Records != 4990 || reports[0].Errors != 10 || reports[0].ByColumn["far_addr"] != 10 {
+		t.Errorf("unexpected report %v, errors by column %v", reports[0], reports[0].ByColumn)
+	}
+	for _, sample := range reports[0].Samples {
+		if sample.File != path || sample.Line < 2 {
+			t.Errorf("sample %v has no file and line", sample)
+		}
+	}
+}
+
+// Builds the export sequentially and in parallel, and returns the sorted CSV
+// exports and the reports of both.
+func buildBoth(t *testing.T, opts buildOptions, path string) ([2]string, [2]*nfp.Report) {
+	t.Helper()
+	var exports [2]string
+	var reports [2]*nfp.Report
+	for i, parallel := range []bool{false, true} {
+		reader := nfp.NewMultiReader([]string{path}, nfp.FormatAuto)
+		var f *ds.FIB
+		var err error
+		if parallel {
+			opts.parseWorkers, opts.insertWorkers = 4, 3
+			f, reports[i], err = opts.buildParallel(reader)
+		} else {
+			f, reports[i], err = opts.buildSequential(reader)
+		}
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := f.Export(&buf, ds.ExportOptions{Format: ds.ExportCSV, Sorted: true}); err != nil {
+			t.Fatal(err)
+		}
+		exports[i] = buf.String()
+	}
+	return exports, reports
+}
+
+func TestBuildLimitCountsRecords(t *testing.T) {
+	path := writeTestExport(t)
+	for _, limit := range []int{0, 17, 100, 4096, 4989, 4990, 10000} {
+		opts := buildOptions{
+			ipv4PrefixLength: 24,
+			ipv6PrefixLength: 48,
+			limit:            limit,
+			bufferSize:       4,
+			initialSize:      16,
+			optimizeIPv4:     true,
+		}
+		exports, reports := buildBoth(t, opts, path)
+		if exports[0] != exports[1] {
+			t.Errorf("limit %v: the sequential and the parallel builds differ", limit)
+		}
+		if !reflect.DeepEqual(reports[0], reports[1]) {
+			t.Errorf("limit %v: sequential report %v, parallel report %v", limit, reports[0], reports[1])
+		}
+		// Ten lines of the export are bad, the limit only counts the others.
+		if want := min(limit, 4990); reports[0].Records != want {
+			t.Errorf("limit %v: read %v records, want %v", limit, reports[0].Records, want)
+		}
+	}
+}
+
+func TestBuildReportsDestinationNetworkFailures(t *testing.T) {
+	path := writeTestFile(t, "records.csv", `near_addr,far_addr,probe_dst_addr
+10.0.0.1,10.0.0.2,8.8.8.8
+10.0.0.1,10.0.0.2,2001:db8::1
+10.0.0.1,10.0.0.3,9.9.9.9
+`)
+	// The flags reject this length, a record whose destination network cannot
+	// be computed must still go to the report and not only to the log.
+	opts := buildOptions{
+		ipv4PrefixLength: 24,
+		ipv6PrefixLength: 200,
+		limit:            -1,
+		bufferSize:       4,
+		initialSize:      16,
+	}
+	exports, reports := buildBoth(t, opts, path)
+	want := `"near_addr","prefix","far_addr","count","first_seen","last_seen","first_round","last_round"
+"10.0.0.1","8.8.8.0/24","10.0.0.2","1","","","",""
+"10.0.0.1","9.9.9.0/24","10.0.0.3","1","","","",""
+`
+	for i, report := range reports {
+		if exports[i] != want {
+			t.Errorf("build %v exports\n%v\nwant\n%v", i, exports[i], want)
+		}
+		if report.Records != 2 || report.Errors != 1 || report.ByColumn["probe_dst_addr"] != 1 {
+			t.Errorf("build %v: unexpected report %v, errors by column %v", i, report, report.ByColumn)
+			continue
+		}
+		if sample := report.Samples[0]; sample.File != path || sample.Line != 3 || sample.Value != "2001:db8::1" {
+			t.Errorf("build %v: unexpected sample %+v", i, sample)
+		}
+	}
+}
+
+func TestPrefixLengthFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		ipv4    int
+		ipv6    int
+		wantErr bool
+	}{
+		{args: nil, ipv4: 24, ipv6: 48},
+		{args: []string{"--prefix-length", "16"}, ipv4: 16, ipv6: 48},
+		{args: []string{"--ipv4-prefix-length", "20", "--prefix-length", "16"}, ipv4: 20, ipv6: 48},
+		{args: []string{"--prefix-length", "16", "--ipv4-prefix-length", "20"}, ipv4: 20, ipv6: 48},
+		{args: []string{"--ipv4-prefix-length", "0", "--ipv6-prefix-length", "128"}, ipv4: 0, ipv6: 128},
+		{args: []string{"--ipv4-prefix-length", "40"}, wantErr: true},
+		{args: []string{"--ipv4-prefix-length", "-1"}, wantErr: true},
+		{args: []string{"--prefix-length", "33"}, wantErr: true},
+		{args: []string{"--ipv6-prefix-length", "129"}, wantErr: true},
+		{args: []string{"--limit", "-1"}, ipv4: 24, ipv6: 48},
+		{args: []string{"--limit", "0"}, ipv4: 24, ipv6: 48},
+		{args: []string{"--limit", "-2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		opts := &buildOptions{}
+		ran := false
+		cmd := &cobra.Command{
+			Use:           "test",
+			SilenceUsage:  true,
+			SilenceErrors: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				ran = true
+				return nil
+			},
+		}
+		opts.addFlags(cmd)
+		cmd.SetArgs(tt.args)
+		cmd.SetErr(io.Discard)
+
+		err := cmd.Execute()
+		if (err != nil) != tt.wantErr || ran == tt.wantErr {
+			t.Errorf("%v: error = %v, ran = %v, want error %v", tt.args, err, ran, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (opts.ipv4PrefixLength != tt.ipv4 || opts.ipv6PrefixLength != tt.ipv6) {
+			t.Errorf("%v: prefix lengths %v/%v, want %v/%v", tt.args, opts.ipv4PrefixLength, opts.ipv6PrefixLength, tt.ipv4, tt.ipv6)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff A.snap B.snap",
+		Short: "Compare two FIB snapshots",
+		Long: `Writes the changes from the first snapshot to the second as JSON Lines: the
+added and removed routers, the added, removed and changed prefixes with their
+added and removed far addresses, and the churn of every changed router.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, _, err := loadSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			b, _, err := loadSnapshot(args[1])
+			if err != nil {
+				return err
+			}
+
+			w := bufio.NewWriter(cmd.OutOrStdout())
+			encoder := json.NewEncoder(w)
+			summary := ds.Diff(a, b, func(change *ds.Change) bool {
+				err = encoder.Encode(change)
+				return err == nil
+			})
+			if err != nil {
+				return err
+			}
+			log.Printf("Done comparing, routers added=%v removed=%v changed=%v unchanged=%v, prefixes added=%v removed=%v changed=%v.\n",
+				summary.RoutersAdded, summary.RoutersRemoved, summary.RoutersChanged, summary.RoutersUnchanged,
+				summary.PrefixesAdded, summary.PrefixesRemoved, summary.PrefixesChanged)
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
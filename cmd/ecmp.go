@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/analysis"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newECMPCommand() *cobra.Command {
+	opts := &buildOptions{}
+	ecmpOptions := analysis.DefaultECMPOptions
+	var format string
+	var sorted bool
+
+	cmd := &cobra.Command{
+		Use:   "ecmp",
+		Short: "Report the load balancing of every router",
+		Long: `Classifies the prefixes of every router as single-path, balanced when the
+next hops share the records evenly, or diverse otherwise. The most common set
+of next hops of a router is reported as a likely balanced bundle when enough
+prefixes use it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exportFormat, err := ds.ParseExportFormat(format)
+			if err != nil {
+				return err
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+			return analysis.WriteECMP(cmd.OutOrStdout(), f, ds.ExportOptions{Format: exportFormat, Sorted: sorted}, ecmpOptions)
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&format, "format", "f", "csv", "output format: csv, tsv or json")
+	flags.BoolVar(&sorted, "sorted", false, "write the routers ordered by their address")
+	flags.Float64Var(&ecmpOptions.BalanceThreshold, "balance-threshold", ecmpOptions.BalanceThreshold, "smallest ratio of the least and the most observed next hops of a balanced prefix")
+	flags.IntVar(&ecmpOptions.MinBundlePrefixes, "min-bundle-prefixes", ecmpOptions.MinBundlePrefixes, "smallest number of prefixes sharing their next hops to report a likely bundle")
+	return cmd
+}
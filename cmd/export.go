@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newExportCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format, asTable string
+	var sorted bool
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the near, network and far address triples of the FIB",
+		Long: `Writes a row for every near address, destination network and far address
+of the FIB. The --as-table file is a CAIDA pfx2as file or a RIB converted to
+text with bgpdump -m, binary MRT dumps are not read.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			annotator, err := loadASTable(asTable)
+			if err != nil {
+				return err
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+			return writeFIB(cmd.OutOrStdout(), f, format, ds.ExportOptions{Sorted: sorted, AS: annotator})
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	cmd.Flags().StringVarP(&format, "format", "f", "csv", "output format: csv, tsv, json or text")
+	cmd.Flags().BoolVar(&sorted, "sorted", false, "write the routers ordered by their address")
+	cmd.Flags().StringVar(&asTable, "as-table", "", "annotate the rows with the origin ASes of this pfx2as file or bgpdump -m text RIB, not a binary MRT dump")
+	return cmd
+}
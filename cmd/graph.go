@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newGraphCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the router-level forwarding graph",
+		Long: `Collapses the FIB into a directed graph with an edge from every near router
+to each of its far addresses. The edges have the number of prefixes routed
+over them and the number of records supporting them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graphFormat, err := ds.ParseGraphFormat(format)
+			if err != nil {
+				return err
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			g := ds.NewGraph(f)
+			log.Printf("Built the graph, nodes=%v, edges=%v.\n", len(g.Nodes), len(g.Edges))
+			return g.Write(cmd.OutOrStdout(), graphFormat)
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	cmd.Flags().StringVarP(&format, "format", "f", "graphml", "output format: graphml, dot or edgelist")
+	return cmd
+}
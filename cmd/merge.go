@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newMergeCommand() *cobra.Command {
+	var output, source string
+
+	cmd := &cobra.Command{
+		Use:   "merge SNAPSHOT...",
+		Short: "Merge FIB snapshots built from different vantage points or days",
+		Long: `Joins the forwarding tables of the snapshots into a single snapshot. The next
+hops of a prefix are the union of its next hops in every snapshot and their
+observations are added up, as if the FIB was built from all the records.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return errors.New("the output snapshot is required")
+			}
+
+			f, header, err := loadSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			records := header.Records
+			sources := []string{header.Source}
+			ipv4PrefixLength, ipv6PrefixLength := f.DefaultPrefixLengths()
+
+			for _, path := range args[1:] {
+				other, header, err := loadSnapshot(path)
+				if err != nil {
+					return err
+				}
+				if header.IPv4PrefixLength != ipv4PrefixLength || header.IPv6PrefixLength != ipv6PrefixLength {
+					log.Printf("Snapshot %v was built with the prefix lengths %v/%v instead of %v/%v.\n",
+						path, header.IPv4PrefixLength, header.IPv6PrefixLength, ipv4PrefixLength, ipv6PrefixLength)
+				}
+				f.Merge(other)
+				records += header.Records
+				sources = append(sources, header.Source)
+			}
+
+			if source == "" {
+				source = strings.Join(sources, ",")
+			}
+			summary := f.Summary()
+			log.Printf("Merged %v snapshots, routers=%v, prefixes=%v.\n", len(args), summary.Routers, summary.Prefixes)
+			return saveSnapshot(output, f, &ds.SnapshotHeader{
+				Source:  source,
+				Records: records,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "save the merged FIB as a snapshot to this file")
+	cmd.Flags().StringVar(&source, "source", "", "source dataset recorded in the snapshot header, defaults to the sources of the snapshots")
+	return cmd
+}
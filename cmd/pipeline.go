@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/netip"
+	"sync"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/nfp"
+)
+
+// Number of lines handed to a parse worker at once.
+const nfpBatchSize = 4096
+
+// insertItem is a parsed record ready to be inserted into the FIB.
+type insertItem struct {
+	nearAddr    netip.Addr
+	dstPrefix   netip.Prefix
+	farAddr     netip.Addr
+	observation ds.Observation
+}
+
+// Builds the FIB with a parallel pipeline. A single reader splits the input
+// into batches of lines, the parse workers parse them and route every record
+// to the insert worker owning the shard of its near address. Since a shard is
+// only written by its own insert worker, the result is the same table as the
+// sequential build. With a limit, the reader parses the records itself so the
+// limit counts the same records as the sequential build, only the inserts
+// run in parallel.
+func (o *buildOptions) buildParallel(reader *nfp.MultiReader) (*ds.FIB, *nfp.Report, error) {
+	parseWorkers := max(o.parseWorkers, 1)
+	insertWorkers := max(o.insertWorkers, 1)
+
+	sharded := ds.NewShardedFIB(uint(insertWorkers), o.initialSize/uint(insertWorkers)+1, o.optimizeIPv4, uint(o.ipv4PrefixLength), uint(o.ipv6PrefixLength))
+
+	batchCh := make(chan *nfp.Batch, o.bufferSize)
+	insertChs := make([]chan []insertItem, insertWorkers)
+	for i := range insertChs {
+		insertChs[i] = make(chan []insertItem, o.bufferSize)
+	}
+
+	// Reader, reads the lines in batches, or the records up to the limit.
+	var readErr error
+	var parseWg sync.WaitGroup
+	parseWg.Add(1)
+	go func() {
+		defer parseWg.Done()
+		defer close(batchCh)
+		if o.limit >= 0 {
+			readErr = o.readRecords(reader, sharded, insertChs)
+		} else {
+			readErr = o.readBatches(reader, batchCh)
+		}
+	}()
+
+	// Parse workers, parse the lines and split them by shard.
+	reports := make([]*nfp.Report, parseWorkers)
+	for w := 0; w < parseWorkers; w++ {
+		reports[w] = nfp.NewReport()
+		parseWg.Add(1)
+		go func(report *nfp.Report) {
+			defer parseWg.Done()
+			for batch := range batchCh {
+				for shard, items := range o.parseBatch(batch, sharded, report) {
+					if len(items) > 0 {
+						insertChs[shard] <- items
+					}
+				}
+			}
+		}(reports[w])
+	}
+	go func() {
+		parseWg.Wait()
+		for _, ch := range insertChs {
+			close(ch)
+		}
+	}()
+
+	// Insert workers, each one owns a single shard.
+	var insertWg sync.WaitGroup
+	for w := 0; w < insertWorkers; w++ {
+		insertWg.Add(1)
+		go func(ch <-chan []insertItem) {
+			defer insertWg.Done()
+			for items := range ch {
+				for _, item := range items {
+					sharded.InsertObservation(item.nearAddr, item.dstPrefix, item.farAddr, item.observation)
+				}
+			}
+		}(insertChs[w])
+	}
+	insertWg.Wait()
+
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+
+	report := nfp.NewReport()
+	report.Merge(reader.Report())
+	for _, r := range reports {
+		report.Merge(r)
+	}
+	return sharded.FIB(), report, nil
+}
+
+// Reads the input in batches of lines until it is over.
+func (o *buildOptions) readBatches(reader *nfp.MultiReader, batchCh chan<- *nfp.Batch) error {
+	progress := newProgress(o.total, o.progressPeriod)
+	for {
+		batch, err := reader.ReadBatch(nfpBatchSize)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		progress.add(batch.Len())
+		batchCh <- batch
+	}
+}
+
+// Reads the records one by one until the limit is reached and sends them to
+// the insert workers in batches.
+func (o *buildOptions) readRecords(reader *nfp.MultiReader, sharded *ds.ShardedFIB, insertChs []chan []insertItem) error {
+	progress := newProgress(o.total, o.progressPeriod)
+	items := make([][]insertItem, sharded.NumShards())
+	flush := func(shard int) {
+		if len(items[shard]) > 0 {
+			insertChs[shard] <- items[shard]
+			items[shard] = nil
+		}
+	}
+	defer func() {
+		for shard := range items {
+			flush(shard)
+		}
+	}()
+
+	for i := 0; i < o.limit; i++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		progress.add(1)
+
+		dstPrefix, ok := o.probeDstPrefix(&record, reader.Report())
+		if !ok {
+			continue
+		}
+		shard := sharded.Shard(record.NearAddr)
+		items[shard] = append(items[shard], insertItem{
+			nearAddr:    record.NearAddr,
+			dstPrefix:   dstPrefix,
+			farAddr:     record.FarAddr,
+			observation: observationOf(&record),
+		})
+		if len(items[shard]) >= nfpBatchSize {
+			flush(shard)
+		}
+	}
+	return nil
+}
+
+// Parses a batch of lines and splits the records by shard.
+func (o *buildOptions) parseBatch(batch *nfp.Batch, sharded *ds.ShardedFIB, report *nfp.Report) [][]insertItem {
+	items := make([][]insertItem, sharded.NumShards())
+
+	batch.Parse(report, func(record *nfp.Record) {
+		dstPrefix, ok := o.probeDstPrefix(record, report)
+		if !ok {
+			return
+		}
+		shard := sharded.Shard(record.NearAddr)
+		items[shard] = append(items[shard], insertItem{
+			nearAddr:    record.NearAddr,
+			dstPrefix:   dstPrefix,
+			farAddr:     record.FarAddr,
+			observation: observationOf(record),
+		})
+	})
+
+	return items
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// queryResult is the answer to a single near, destination query. The ASes
+// are only set with an AS table, 0 is unknown.
+type queryResult struct {
+	Near      netip.Addr   `json:"near"`
+	Dst       netip.Addr   `json:"dst"`
+	Found     bool         `json:"found"`
+	Prefix    string       `json:"prefix,omitempty"`
+	NextHops  []netip.Addr `json:"next_hops"`
+	NearAS    uint32       `json:"near_as,omitempty"`
+	DstAS     uint32       `json:"dst_as,omitempty"`
+	NextHopAS []uint32     `json:"next_hop_as,omitempty"`
+}
+
+// Sets the origin ASes of the near router, the destination and the next hops.
+func (r *queryResult) annotate(annotator ds.ASAnnotator) {
+	r.NearAS, _ = annotator.OriginAS(r.Near)
+	r.DstAS, _ = annotator.OriginAS(r.Dst)
+	r.NextHopAS = make([]uint32, len(r.NextHops))
+	for i, nextHop := range r.NextHops {
+		r.NextHopAS[i], _ = annotator.OriginAS(nextHop)
+	}
+}
+
+func newQueryCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format, queries, asTable string
+
+	cmd := &cobra.Command{
+		Use:   "query [NEAR DST]...",
+		Short: "Print the next hops the near routers use toward the destinations",
+		Long: `Performs a longest prefix match for every destination on the forwarding
+table of its near router. The near and destination pairs are given as
+arguments or, without arguments, read from the queries file one pair per line.
+
+The --as-table file is a CAIDA pfx2as file or a RIB converted to text with
+bgpdump -m, binary MRT dumps are not read.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args)%2 != 0 {
+				return errors.New("the arguments must be near and destination pairs")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && queries == "-" && opts.snapshot == "" && slices.Contains(opts.inputs, "-") {
+				return errors.New("cannot read both the NFP records and the queries from stdin")
+			}
+			pairs, err := queryPairs(args, queries)
+			if err != nil {
+				return err
+			}
+
+			annotator, err := loadASTable(asTable)
+			if err != nil {
+				return err
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			w, err := newQueryWriter(cmd.OutOrStdout(), format, annotator != nil)
+			if err != nil {
+				return err
+			}
+			for _, pair := range pairs {
+				prefix, entry, found := f.LookupAddr(pair[0], pair[1])
+				result := queryResult{Near: pair[0], Dst: pair[1], Found: found}
+				if found {
+					result.Prefix = prefix.String()
+					result.NextHops = entry.Addrs()
+				}
+				if annotator != nil {
+					result.annotate(annotator)
+				}
+				if err := w.write(&result); err != nil {
+					return err
+				}
+			}
+			return w.flush()
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "output format: text, csv or json")
+	cmd.Flags().StringVarP(&queries, "queries", "q", "-", "file of near and destination pairs used without arguments, - for stdin")
+	cmd.Flags().StringVar(&asTable, "as-table", "", "annotate the results with the origin ASes of this pfx2as file or bgpdump -m text RIB, not a binary MRT dump")
+	return cmd
+}
+
+// Returns the queried pairs from the arguments, or from the queries file if
+// there are no arguments.
+func queryPairs(args []string, queries string) ([][2]netip.Addr, error) {
+	if len(args) > 0 {
+		pairs := make([][2]netip.Addr, 0, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			pair, err := parseQueryPair(args[i], args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs, nil
+	}
+
+	in := io.Reader(os.Stdin)
+	if queries != "-" {
+		file, err := os.Open(queries)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var pairs [][2]netip.Addr
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("queries line %v: expected a near and a destination address", lineNumber)
+		}
+		pair, err := parseQueryPair(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("queries line %v: %w", lineNumber, err)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, scanner.Err()
+}
+
+func parseQueryPair(near, dst string) ([2]netip.Addr, error) {
+	nearAddr, err := netip.ParseAddr(strings.Trim(near, `"`))
+	if err != nil {
+		return [2]netip.Addr{}, fmt.Errorf("invalid near address %q", near)
+	}
+	dstAddr, err := netip.ParseAddr(strings.Trim(dst, `"`))
+	if err != nil {
+		return [2]netip.Addr{}, fmt.Errorf("invalid destination address %q", dst)
+	}
+	return [2]netip.Addr{nearAddr.Unmap(), dstAddr.Unmap()}, nil
+}
+
+// queryWriter writes the query results in one of the output formats.
+type queryWriter struct {
+	format    string
+	annotated bool
+	w         *bufio.Writer
+	csv       *csv.Writer
+	json      *json.Encoder
+}
+
+func newQueryWriter(out io.Writer, format string, annotated bool) (*queryWriter, error) {
+	w := &queryWriter{format: format, annotated: annotated, w: bufio.NewWriter(out)}
+	switch format {
+	case "text":
+	case "csv":
+		w.csv = csv.NewWriter(w.w)
+		header := []string{"near_addr", "dst_addr", "prefix", "far_addr"}
+		if annotated {
+			header = append(header, "near_as", "dst_as", "far_as", "inter_as")
+		}
+		if err := w.csv.Write(header); err != nil {
+			return nil, err
+		}
+	case "json":
+		w.json = json.NewEncoder(w.w)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	return w, nil
+}
+
+func (w *queryWriter) write(r *queryResult) error {
+	switch w.format {
+	case "csv":
+		if !r.Found {
+			row := []string{r.Near.String(), r.Dst.String(), "", ""}
+			if w.annotated {
+				row = append(row, formatAS(r.NearAS), formatAS(r.DstAS), "", "")
+			}
+			return w.csv.Write(row)
+		}
+		for i, nextHop := range r.NextHops {
+			row := []string{r.Near.String(), r.Dst.String(), r.Prefix, nextHop.String()}
+			if w.annotated {
+				row = append(row, formatAS(r.NearAS), formatAS(r.DstAS), formatAS(r.NextHopAS[i]),
+					formatInterAS(r.NearAS, r.NextHopAS[i]))
+			}
+			if err := w.csv.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		if r.NextHops == nil {
+			r.NextHops = []netip.Addr{}
+		}
+		return w.json.Encode(r)
+	default:
+		if !r.Found {
+			_, err := fmt.Fprintf(w.w, "%v -> %v: no route\n", r.Near, r.Dst)
+			return err
+		}
+		if w.annotated {
+			nextHops := make([]string, len(r.NextHops))
+			for i, nextHop := range r.NextHops {
+				nextHops[i] = fmt.Sprintf("%v (AS%v)", nextHop, labelAS(r.NextHopAS[i]))
+			}
+			_, err := fmt.Fprintf(w.w, "%v (AS%v) -> %v (AS%v): %v {%v}\n", r.Near, labelAS(r.NearAS),
+				r.Dst, labelAS(r.DstAS), r.Prefix, strings.Join(nextHops, ", "))
+			return err
+		}
+		_, err := fmt.Fprintf(w.w, "%v -> %v: %v %v\n", r.Near, r.Dst, r.Prefix, formatAddrs(r.NextHops))
+		return err
+	}
+}
+
+func (w *queryWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// Formats the AS, empty if it is unknown.
+func formatAS(as uint32) string {
+	if as == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(as), 10)
+}
+
+// Formats the AS for the text output, ? if it is unknown.
+func labelAS(as uint32) string {
+	if as == 0 {
+		return "?"
+	}
+	return strconv.FormatUint(uint64(as), 10)
+}
+
+// Formats whether the edge crosses ASes, empty if one of them is unknown.
+func formatInterAS(nearAS, farAS uint32) string {
+	if nearAS == 0 || farAS == 0 {
+		return ""
+	}
+	return strconv.FormatBool(nearAS != farAS)
+}
+
+// Formats the addresses as a set, like FTEntry.String.
+func formatAddrs(addrs []netip.Addr) string {
+	parts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		parts[i] = addr.String()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
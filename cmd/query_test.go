@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Writes the content into a file of the test directory and returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Runs the query command with the arguments and returns its output.
+func runQuery(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newQueryCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+const testQueryRecords = `near_addr,far_addr,probe_dst_addr
+10.0.0.1,10.0.0.2,8.8.8.8
+10.0.0.1,10.0.0.3,8.8.8.9
+10.0.0.1,10.0.0.4,9.9.9.9
+10.0.0.1,10.0.0.5,2001:db8::1
+`
+
+func TestQueryCommand(t *testing.T) {
+	records := writeTestFile(t, "records.csv", testQueryRecords)
+	queries := writeTestFile(t, "queries.txt", "# near, dst\n10.0.0.1,8.8.8.1\n\n10.0.0.1 1.1.1.1\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"text", []string{"10.0.0.1", "8.8.8.200", "10.0.0.1", "2001:db8::9", "10.0.0.9", "8.8.8.8"}, `10.0.0.1 -> 8.8.8.200: 8.8.8.0/24 {10.0.0.2, 10.0.0.3}
+10.0.0.1 -> 2001:db8::9: 2001:db8::/48 {10.0.0.5}
+10.0.0.9 -> 8.8.8.8: no route
+`},
+		{"csv", []string{"-f", "csv", "10.0.0.1", "::ffff:8.8.8.1", "10.0.0.1", "7.7.7.7"}, `near_addr,dst_addr,prefix,far_addr
+10.0.0.1,8.8.8.1,8.8.8.0/24,10.0.0.2
+10.0.0.1,8.8.8.1,8.8.8.0/24,10.0.0.3
+10.0.0.1,7.7.7.7,,
+`},
+		{"json", []string{"-f", "json", "10.0.0.1", "9.9.9.1", "10.0.0.1", "7.7.7.7"}, `{"near":"10.0.0.1","dst":"9.9.9.1","found":true,"prefix":"9.9.9.0/24","next_hops":["10.0.0.4"]}
+{"near":"10.0.0.1","dst":"7.7.7.7","found":false,"next_hops":[]}
+`},
+		{"queries file", []string{"-q", queries}, `10.0.0.1 -> 8.8.8.1: 8.8.8.0/24 {10.0.0.2, 10.0.0.3}
+10.0.0.1 -> 1.1.1.1: no route
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runQuery(t, append([]string{"-i", records, "--parse-workers", "1", "--insert-workers", "1"}, tt.args...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCommandErrors(t *testing.T) {
+	records := writeTestFile(t, "records.csv", testQueryRecords)
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"odd arguments", []string{"-i", records, "10.0.0.1"}, "the arguments must be near and destination pairs"},
+		{"bad near", []string{"-i", records, "foo", "8.8.8.8"}, `invalid near address "foo"`},
+		{"bad destination", []string{"-i", records, "10.0.0.1", "8.8.8.0/24"}, `invalid destination address "8.8.8.0/24"`},
+		{"bad queries line", []string{"-i", records, "-q", writeTestFile(t, "queries.txt", "10.0.0.1 8.8.8.8\n10.0.0.1\n")},
+			"queries line 2: expected a near and a destination address"},
+		{"format", []string{"-i", records, "-f", "xml", "10.0.0.1", "8.8.8.8"}, `unknown output format "xml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runQuery(t, tt.args...); err == nil || err.Error() != tt.err {
+				t.Errorf("got the error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestQueryCommandRejectsStdinTwice(t *testing.T) {
+	stdin := writeTestFile(t, "stdin.txt", strings.Repeat("10.0.0.1 8.8.8.8\n", 100))
+	in, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	saved := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = saved }()
+
+	if _, err := runQuery(t, "-i", "-", "-q", "-"); err == nil {
+		t.Fatal("expected an error")
+	}
+	// The error comes before the queries are read.
+	if offset, err := in.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		t.Errorf("stdin was read up to %v before the error", offset)
+	}
+}
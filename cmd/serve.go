@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/server"
+)
+
+func newServeCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var listen string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve lookups over the FIB with an HTTP/JSON API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			httpServer := &http.Server{
+				Addr:              listen,
+				Handler:           server.New(f),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				httpServer.Shutdown(shutdownCtx)
+			}()
+
+			log.Printf("Serving the FIB on %v.\n", listen)
+			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	cmd.Flags().StringVarP(&listen, "listen", "l", "localhost:8080", "address the server listens on")
+	return cmd
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newStatsCommand() *cobra.Command {
+	opts := &buildOptions{}
+	var format string
+	var sorted bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Print the prefix, next hop and covered address statistics of every router",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exportFormat, err := ds.ParseExportFormat(format)
+			if err != nil {
+				return err
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+			return f.WriteStats(cmd.OutOrStdout(), ds.ExportOptions{Format: exportFormat, Sorted: sorted})
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	cmd.Flags().StringVarP(&format, "format", "f", "csv", "output format: csv, tsv or json")
+	cmd.Flags().BoolVar(&sorted, "sorted", false, "write the routers ordered by their address")
+	return cmd
+}
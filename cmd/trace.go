@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/ubombar/routeinfo/pkg/analysis"
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+func newTraceCommand() *cobra.Command {
+	opts := &buildOptions{}
+	traceOptions := analysis.DefaultTraceOptions
+	var format, from string
+
+	cmd := &cobra.Command{
+		Use:   "trace DESTINATION...",
+		Short: "Simulate the forwarding toward destinations through the FIB",
+		Long: `Follows the forwarding tables hop by hop toward every destination, an
+address or a prefix, the far addresses becoming the next near routers. Every
+path is reported as complete when it reaches the destination, as a loop, as a
+dead end when a router has no route, or as truncated, with the routers where
+it diverges from the other paths. The paths start from the given router, or
+from every router with a route that is not the next hop of another one.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exportFormat, err := ds.ParseExportFormat(format)
+			if err != nil {
+				return err
+			}
+			destinations := make([]netip.Prefix, len(args))
+			for i, arg := range args {
+				if destinations[i], err = parseDestination(arg); err != nil {
+					return err
+				}
+			}
+			var start netip.Addr
+			if from != "" {
+				if start, err = netip.ParseAddr(from); err != nil {
+					return err
+				}
+			}
+			f, err := opts.load()
+			if err != nil {
+				return err
+			}
+
+			var paths []*analysis.TracePath
+			for _, destination := range destinations {
+				sources := []netip.Addr{start}
+				if !start.IsValid() {
+					sources = analysis.TraceSources(f, destination)
+				}
+				var outcomes [4]int
+				for _, source := range sources {
+					for _, path := range analysis.Trace(f, source, destination, traceOptions) {
+						outcomes[path.Outcome]++
+						paths = append(paths, path)
+					}
+				}
+				log.Printf("Traced %v from %v routers, complete=%v, loop=%v, dead_end=%v, truncated=%v.\n",
+					destination, len(sources), outcomes[analysis.TraceComplete], outcomes[analysis.TraceLoop],
+					outcomes[analysis.TraceDeadEnd], outcomes[analysis.TraceTruncated])
+			}
+			return analysis.WriteTraces(cmd.OutOrStdout(), paths, exportFormat)
+		},
+	}
+
+	opts.addLoadFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&format, "format", "f", "csv", "output format: csv, tsv or json")
+	flags.StringVar(&from, "from", "", "start the paths from this router")
+	flags.IntVar(&traceOptions.MaxHops, "max-hops", traceOptions.MaxHops, "largest number of hops of a path")
+	flags.IntVar(&traceOptions.MaxPaths, "max-paths", traceOptions.MaxPaths, "largest number of paths from a router")
+	return cmd
+}
+
+// Parses a destination prefix, an address is a prefix of its full length.
+func parseDestination(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid destination %v: %w", s, err)
+		}
+		prefix = ds.UnmapPrefix(prefix)
+		if !prefix.IsValid() {
+			return netip.Prefix{}, fmt.Errorf("invalid destination %v", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid destination %v: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{"8.8.8.8", "8.8.8.8/32", false},
+		{"::ffff:8.8.8.8", "8.8.8.8/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"8.8.8.8/24", "8.8.8.0/24", false},
+		{"::ffff:8.8.8.0/120", "8.8.8.0/24", false},
+		{"::ffff:8.8.8.8/96", "0.0.0.0/0", false},
+		{"::ffff:8.8.8.8/128", "8.8.8.8/32", false},
+		{"::ffff:0.0.0.0/80", "::/80", false},
+		{"2001:db8::1/32", "2001:db8::/32", false},
+		{"8.8.8.8/33", "", true},
+		{"8.8.8.8/", "", true},
+		{"foo", "", true},
+		{"foo/24", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDestination(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("parseDestination(%q) returned the error %v", tt.input, err)
+			continue
+		}
+		if !tt.err && got != netip.MustParsePrefix(tt.want) {
+			t.Errorf("parseDestination(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,8 @@ go 1.23.4
 
 require (
 	github.com/armon/go-radix v1.0.0
+	github.com/klauspost/compress v1.18.0
+	github.com/pierrec/lz4/v4 v4.1.22
 	github.com/spf13/cobra v1.9.1
 )
 
@@ -0,0 +1,315 @@
+package analysis
+
+import (
+	"cmp"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// AliasEvidence is the set of reasons for two near addresses to be
+// interfaces of the same router.
+type AliasEvidence uint8
+
+const (
+	// The routers forward most of their common prefixes to the same next
+	// hops.
+	AliasSimilarTable AliasEvidence = 1 << iota
+	// The routers have mostly the same next hops.
+	AliasSharedNextHops
+	// One of the addresses is the other end of the point to point subnet of a
+	// next hop of the other, so it is the interface the router forwards from.
+	AliasSubnetMate
+)
+
+func (e AliasEvidence) String() string {
+	var names []string
+	if e&AliasSimilarTable != 0 {
+		names = append(names, "similar_table")
+	}
+	if e&AliasSharedNextHops != 0 {
+		names = append(names, "shared_next_hops")
+	}
+	if e&AliasSubnetMate != 0 {
+		names = append(names, "subnet_mate")
+	}
+	return strings.Join(names, "|")
+}
+
+// AliasOptions are the thresholds of the alias hints.
+type AliasOptions struct {
+	// Smallest share of the common prefixes with the same next hops, and
+	// smallest Jaccard index of the next hops, of a hint.
+	MinSimilarity float64
+	// Smallest number of common prefixes with the same next hops, or of
+	// common next hops, of a hint.
+	MinShared int
+	// Prefixes and next hops shared by more routers are ignored, they say
+	// little about the routers and the pairs grow quadratically.
+	MaxGroupSize int
+}
+
+// Default thresholds of the alias hints.
+var DefaultAliasOptions = AliasOptions{
+	MinSimilarity: 0.8,
+	MinShared:     5,
+	MaxGroupSize:  100,
+}
+
+// AliasHint is a pair of near addresses likely on the same router, A is the
+// smallest.
+type AliasHint struct {
+	A        netip.Addr
+	B        netip.Addr
+	Evidence AliasEvidence
+	// Share of the common prefixes with the same next hops.
+	TableSimilarity float64
+	// Jaccard index of the next hops.
+	NextHopSimilarity float64
+}
+
+type aliasPair [2]netip.Addr
+
+func newAliasPair(a, b netip.Addr) aliasPair {
+	if b.Less(a) {
+		a, b = b, a
+	}
+	return aliasPair{a, b}
+}
+
+// Finds the pairs of near addresses of the FIB likely to be interfaces of the
+// same router. A router is never paired with one of its next hops. The hints
+// are ordered by their addresses.
+func FindAliasHints(f *ds.FIB, opts AliasOptions) []*AliasHint {
+	hints := make(map[aliasPair]*AliasHint)
+	hint := func(pair aliasPair) *AliasHint {
+		h, found := hints[pair]
+		if !found {
+			h = &AliasHint{A: pair[0], B: pair[1]}
+			hints[pair] = h
+		}
+		return h
+	}
+
+	nextHops := make(map[netip.Addr]map[netip.Addr]bool, f.Len())
+	tables := make(map[string][]netip.Addr)
+	var key strings.Builder
+	f.Walk(func(near netip.Addr, ft *ds.FT) bool {
+		set := make(map[netip.Addr]bool)
+		ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+			key.Reset()
+			key.WriteString(prefix.String())
+			for _, far := range entry.Addrs() {
+				set[far] = true
+				key.WriteByte(' ')
+				key.WriteString(far.String())
+			}
+			tables[key.String()] = append(tables[key.String()], near)
+			return true
+		})
+		nextHops[near] = set
+		return true
+	})
+	adjacent := func(pair aliasPair) bool {
+		return nextHops[pair[0]][pair[1]] || nextHops[pair[1]][pair[0]]
+	}
+
+	for pair, equal := range countPairs(tables, opts.MaxGroupSize) {
+		if equal < opts.MinShared || adjacent(pair) {
+			continue
+		}
+		a, _ := f.GetAddr(pair[0])
+		b, _ := f.GetAddr(pair[1])
+		if similarity := float64(equal) / float64(commonPrefixes(a, b)); similarity >= opts.MinSimilarity {
+			h := hint(pair)
+			h.Evidence |= AliasSimilarTable
+			h.TableSimilarity = similarity
+		}
+	}
+
+	routers := make(map[netip.Addr][]netip.Addr)
+	for near, set := range nextHops {
+		for far := range set {
+			routers[far] = append(routers[far], near)
+		}
+	}
+	for pair, shared := range countPairs(routers, opts.MaxGroupSize) {
+		if shared < opts.MinShared || adjacent(pair) {
+			continue
+		}
+		union := len(nextHops[pair[0]]) + len(nextHops[pair[1]]) - shared
+		if similarity := float64(shared) / float64(union); similarity >= opts.MinSimilarity {
+			h := hint(pair)
+			h.Evidence |= AliasSharedNextHops
+			h.NextHopSimilarity = similarity
+		}
+	}
+
+	for near, set := range nextHops {
+		for far := range set {
+			for _, mate := range subnetMates(far) {
+				if _, found := nextHops[mate]; !found || mate == near {
+					continue
+				}
+				if pair := newAliasPair(near, mate); !adjacent(pair) {
+					hint(pair).Evidence |= AliasSubnetMate
+				}
+			}
+		}
+	}
+
+	result := make([]*AliasHint, 0, len(hints))
+	for _, h := range hints {
+		result = append(result, h)
+	}
+	slices.SortFunc(result, func(a, b *AliasHint) int {
+		return cmp.Or(a.A.Compare(b.A), a.B.Compare(b.B))
+	})
+	return result
+}
+
+// Counts how many groups every pair of routers is in, the groups larger than
+// maxGroupSize are skipped.
+func countPairs[K comparable](groups map[K][]netip.Addr, maxGroupSize int) map[aliasPair]int {
+	counts := make(map[aliasPair]int)
+	for _, group := range groups {
+		if len(group) < 2 || len(group) > maxGroupSize {
+			continue
+		}
+		for i, a := range group {
+			for _, b := range group[i+1:] {
+				counts[newAliasPair(a, b)]++
+			}
+		}
+	}
+	return counts
+}
+
+// Returns the number of prefixes in both tables.
+func commonPrefixes(a, b *ds.FT) int {
+	if a.Len() > b.Len() {
+		a, b = b, a
+	}
+	common := 0
+	a.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+		if _, found := b.ContainsPrefix(prefix); found {
+			common++
+		}
+		return true
+	})
+	return common
+}
+
+// Returns the other ends of the point to point subnets the address can be in:
+// the /31 one, and the /30 one when the address is not the network or the
+// broadcast address of its /30. IPv6 addresses use /127 and /126.
+func subnetMates(address netip.Addr) []netip.Addr {
+	b := address.AsSlice()
+	last := len(b) - 1
+
+	mates := make([]netip.Addr, 0, 2)
+	b[last] ^= 1
+	mate, _ := netip.AddrFromSlice(b)
+	mates = append(mates, mate)
+	b[last] ^= 1
+
+	if host := b[last] & 3; host == 1 || host == 2 {
+		b[last] ^= 3
+		mate, _ = netip.AddrFromSlice(b)
+		mates = append(mates, mate)
+	}
+	return mates
+}
+
+// Groups the hinted addresses into alias sets, the pairs are joined
+// transitively. The sets are ordered by their smallest address and named A1,
+// A2 and so on. The evidence of every set is returned with it.
+func GroupAliases(hints []*AliasHint) (*ds.AliasSets, []AliasEvidence) {
+	parent := make(map[netip.Addr]netip.Addr)
+	var find func(netip.Addr) netip.Addr
+	find = func(addr netip.Addr) netip.Addr {
+		p, found := parent[addr]
+		if !found || p == addr {
+			return addr
+		}
+		root := find(p)
+		parent[addr] = root
+		return root
+	}
+	for _, h := range hints {
+		a, b := find(h.A), find(h.B)
+		if a == b {
+			continue
+		}
+		if b.Less(a) {
+			a, b = b, a
+		}
+		parent[a] = a
+		parent[b] = a
+	}
+
+	members := make(map[netip.Addr][]netip.Addr)
+	for addr := range parent {
+		root := find(addr)
+		members[root] = append(members[root], addr)
+	}
+	evidence := make(map[netip.Addr]AliasEvidence)
+	for _, h := range hints {
+		evidence[find(h.A)] |= h.Evidence
+	}
+
+	roots := make([]netip.Addr, 0, len(members))
+	for root := range members {
+		roots = append(roots, root)
+	}
+	slices.SortFunc(roots, netip.Addr.Compare)
+
+	sets := ds.NewAliasSets()
+	setEvidence := make([]AliasEvidence, len(roots))
+	for i, root := range roots {
+		sets.Add("A"+strconv.Itoa(i+1), members[root]...)
+		setEvidence[i] = evidence[root]
+	}
+	return sets, setEvidence
+}
+
+// Writes the alias hints, one row per pair. The similarities are written
+// empty when they are not part of the evidence.
+func WriteAliasHints(w io.Writer, hints []*AliasHint, format ds.ExportFormat) error {
+	t, err := ds.NewTableWriter(w, format, "addr_a", "addr_b", "evidence", "table_similarity", "next_hop_similarity")
+	if err != nil {
+		return err
+	}
+	for _, h := range hints {
+		var tableSimilarity, nextHopSimilarity any
+		if h.Evidence&AliasSimilarTable != 0 {
+			tableSimilarity = h.TableSimilarity
+		}
+		if h.Evidence&AliasSharedNextHops != 0 {
+			nextHopSimilarity = h.NextHopSimilarity
+		}
+		if err := t.Write(h.A, h.B, h.Evidence.String(), tableSimilarity, nextHopSimilarity); err != nil {
+			return err
+		}
+	}
+	return t.Flush()
+}
+
+// Writes the alias sets with their evidence, one row per set. The addresses
+// are separated by spaces.
+func WriteAliasSets(w io.Writer, sets *ds.AliasSets, evidence []AliasEvidence, format ds.ExportFormat) error {
+	t, err := ds.NewTableWriter(w, format, "id", "size", "evidence", "addrs")
+	if err != nil {
+		return err
+	}
+	for i, set := range sets.Sets() {
+		if err := t.Write(set.ID, len(set.Addrs), evidence[i].String(), joinAddrs(set.Addrs)); err != nil {
+			return err
+		}
+	}
+	return t.Flush()
+}
@@ -0,0 +1,211 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/ds/dstest"
+)
+
+// Thresholds small enough for the test FIBs.
+var testAliasOptions = AliasOptions{MinSimilarity: 0.8, MinShared: 2, MaxGroupSize: 3}
+
+// Builds a FIB with a pair of routers for every kind of evidence, and routers
+// that must not be paired.
+func testAliasFIB() *ds.FIB {
+	return dstest.NewFIB(
+		// The same table and next hops, with a prefix only one of them has.
+		[3]string{"10.1.0.10", "8.8.1.0/24", "10.9.1.1"},
+		[3]string{"10.1.0.10", "8.8.2.0/24", "10.9.1.2"},
+		[3]string{"10.1.0.10", "8.8.3.0/24", "10.9.1.3"},
+		[3]string{"10.1.0.10", "7.7.7.0/24", "10.9.1.1"},
+		[3]string{"10.1.0.20", "8.8.1.0/24", "10.9.1.1"},
+		[3]string{"10.1.0.20", "8.8.2.0/24", "10.9.1.2"},
+		[3]string{"10.1.0.20", "8.8.3.0/24", "10.9.1.3"},
+		// Two of three common prefixes with the same next hops, and half of
+		// the next hops shared, below the thresholds.
+		[3]string{"10.2.0.10", "6.6.1.0/24", "10.9.2.1"},
+		[3]string{"10.2.0.10", "6.6.2.0/24", "10.9.2.2"},
+		[3]string{"10.2.0.10", "6.6.3.0/24", "10.9.2.5"},
+		[3]string{"10.2.0.20", "6.6.1.0/24", "10.9.2.1"},
+		[3]string{"10.2.0.20", "6.6.2.0/24", "10.9.2.2"},
+		[3]string{"10.2.0.20", "6.6.3.0/24", "10.9.2.9"},
+		// The same next hops for different prefixes.
+		[3]string{"10.3.0.10", "5.5.1.0/24", "10.9.3.1"},
+		[3]string{"10.3.0.10", "5.5.2.0/24", "10.9.3.2"},
+		[3]string{"10.3.0.20", "5.5.3.0/24", "10.9.3.1"},
+		[3]string{"10.3.0.20", "5.5.4.0/24", "10.9.3.2"},
+		// A next hop whose /30 mate is a router.
+		[3]string{"10.4.0.10", "4.4.1.0/24", "10.4.1.1"},
+		[3]string{"10.4.1.2", "4.4.2.0/24", "10.9.4.9"},
+		// A next hop whose /31 mate is a router.
+		[3]string{"10.4.0.20", "4.4.3.0/24", "10.4.2.4"},
+		[3]string{"10.4.2.5", "4.4.4.0/24", "10.9.4.9"},
+		// A next hop at the start of its /30, the router at its end is not a
+		// mate.
+		[3]string{"10.4.0.30", "4.4.5.0/24", "10.4.3.4"},
+		[3]string{"10.4.3.7", "4.4.6.0/24", "10.9.4.8"},
+		// Mates forwarding to each other are adjacent routers.
+		[3]string{"10.4.0.40", "4.4.7.0/24", "10.4.5.1"},
+		[3]string{"10.4.5.2", "4.4.8.0/24", "10.4.0.40"},
+		// The same table on more routers than the largest group.
+		[3]string{"10.5.0.10", "3.3.1.0/24", "10.9.5.1"},
+		[3]string{"10.5.0.10", "3.3.2.0/24", "10.9.5.2"},
+		[3]string{"10.5.0.20", "3.3.1.0/24", "10.9.5.1"},
+		[3]string{"10.5.0.20", "3.3.2.0/24", "10.9.5.2"},
+		[3]string{"10.5.0.30", "3.3.1.0/24", "10.9.5.1"},
+		[3]string{"10.5.0.30", "3.3.2.0/24", "10.9.5.2"},
+		[3]string{"10.5.0.40", "3.3.1.0/24", "10.9.5.1"},
+		[3]string{"10.5.0.40", "3.3.2.0/24", "10.9.5.2"},
+	)
+}
+
+func TestFindAliasHints(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAliasHints(&buf, FindAliasHints(testAliasFIB(), testAliasOptions), ds.ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := `"addr_a","addr_b","evidence","table_similarity","next_hop_similarity"
+"10.1.0.10","10.1.0.20","similar_table|shared_next_hops","1","1"
+"10.3.0.10","10.3.0.20","shared_next_hops","","1"
+"10.4.0.10","10.4.1.2","subnet_mate","",""
+"10.4.0.20","10.4.2.5","subnet_mate","",""
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFindAliasHintsMaxGroupSize(t *testing.T) {
+	// Only the routers of the larger group are paired once it fits.
+	opts := testAliasOptions
+	opts.MaxGroupSize = 4
+	var pairs []string
+	for _, h := range FindAliasHints(testAliasFIB(), opts) {
+		if netip.MustParsePrefix("10.5.0.0/16").Contains(h.A) {
+			pairs = append(pairs, fmt.Sprintf("%v %v %v", h.A, h.B, h.Evidence))
+		}
+	}
+	want := []string{
+		"10.5.0.10 10.5.0.20 similar_table|shared_next_hops",
+		"10.5.0.10 10.5.0.30 similar_table|shared_next_hops",
+		"10.5.0.10 10.5.0.40 similar_table|shared_next_hops",
+		"10.5.0.20 10.5.0.30 similar_table|shared_next_hops",
+		"10.5.0.20 10.5.0.40 similar_table|shared_next_hops",
+		"10.5.0.30 10.5.0.40 similar_table|shared_next_hops",
+	}
+	if !slices.Equal(pairs, want) {
+		t.Errorf("got %v, want %v", pairs, want)
+	}
+}
+
+func TestCountPairs(t *testing.T) {
+	a, b, c := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.3")
+	groups := map[string][]netip.Addr{
+		"abc": {c, a, b},
+		"ab":  {b, a},
+		"a":   {a},
+	}
+	tests := []struct {
+		maxGroupSize int
+		want         map[aliasPair]int
+	}{
+		{3, map[aliasPair]int{{a, b}: 2, {a, c}: 1, {b, c}: 1}},
+		{2, map[aliasPair]int{{a, b}: 1}},
+		{1, map[aliasPair]int{}},
+	}
+	for _, tt := range tests {
+		if got := countPairs(groups, tt.maxGroupSize); !maps.Equal(got, tt.want) {
+			t.Errorf("maxGroupSize %v: got %v, want %v", tt.maxGroupSize, got, tt.want)
+		}
+	}
+}
+
+func TestCommonPrefixes(t *testing.T) {
+	f := dstest.NewFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.9.0.1"},
+		[3]string{"10.0.0.1", "8.8.0.0/16", "10.9.0.1"},
+		[3]string{"10.0.0.1", "9.9.9.0/24", "10.9.0.1"},
+		[3]string{"10.0.0.1", "2001:db8::/48", "10.9.0.1"},
+		[3]string{"10.0.0.2", "8.8.8.0/24", "10.9.0.2"},
+		[3]string{"10.0.0.2", "8.8.0.0/17", "10.9.0.2"},
+		[3]string{"10.0.0.2", "2001:db8::/48", "10.9.0.2"},
+	)
+	a, _ := f.GetAddr(netip.MustParseAddr("10.0.0.1"))
+	b, _ := f.GetAddr(netip.MustParseAddr("10.0.0.2"))
+	// Only the exact prefixes count, whatever their next hops.
+	if got := commonPrefixes(a, b); got != 2 {
+		t.Errorf("commonPrefixes(a, b) = %v, want 2", got)
+	}
+	if got := commonPrefixes(b, a); got != 2 {
+		t.Errorf("commonPrefixes(b, a) = %v, want 2", got)
+	}
+}
+
+func TestSubnetMates(t *testing.T) {
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{"10.0.0.0", []string{"10.0.0.1"}},
+		{"10.0.0.1", []string{"10.0.0.0", "10.0.0.2"}},
+		{"10.0.0.2", []string{"10.0.0.3", "10.0.0.1"}},
+		{"10.0.0.3", []string{"10.0.0.2"}},
+		{"10.0.0.5", []string{"10.0.0.4", "10.0.0.6"}},
+		{"2001:db8::1", []string{"2001:db8::", "2001:db8::2"}},
+		{"2001:db8::3", []string{"2001:db8::2"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, mate := range subnetMates(netip.MustParseAddr(tt.address)) {
+			got = append(got, mate.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("subnetMates(%v) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestGroupAliases(t *testing.T) {
+	addr := func(i byte) netip.Addr { return netip.AddrFrom4([4]byte{10, 0, 0, i}) }
+	// 1-2, 3-4 and 2-4 join transitively into one set, in any order.
+	hints := []*AliasHint{
+		{A: addr(3), B: addr(4), Evidence: AliasSharedNextHops},
+		{A: addr(5), B: addr(6), Evidence: AliasSubnetMate},
+		{A: addr(1), B: addr(2), Evidence: AliasSimilarTable},
+		{A: addr(2), B: addr(4), Evidence: AliasSimilarTable},
+		{A: addr(7), B: addr(9), Evidence: AliasSubnetMate},
+		{A: addr(8), B: addr(9), Evidence: AliasSubnetMate},
+	}
+	sets, evidence := GroupAliases(hints)
+
+	var buf bytes.Buffer
+	if err := WriteAliasSets(&buf, sets, evidence, ds.ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := `"id","size","evidence","addrs"
+"A1","4","similar_table|shared_next_hops","10.0.0.1 10.0.0.2 10.0.0.3 10.0.0.4"
+"A2","2","subnet_mate","10.0.0.5 10.0.0.6"
+"A3","3","subnet_mate","10.0.0.7 10.0.0.8 10.0.0.9"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+	for i := byte(1); i <= 4; i++ {
+		if set, _ := sets.Router(addr(i)); set.ID != "A1" {
+			t.Errorf("%v is in %v, want A1", addr(i), set.ID)
+		}
+	}
+}
+
+func TestGroupAliasesOfNoHints(t *testing.T) {
+	sets, evidence := GroupAliases(nil)
+	if sets.Len() != 0 || len(evidence) != 0 {
+		t.Errorf("got %v sets and %v evidences, want none", sets.Len(), len(evidence))
+	}
+}
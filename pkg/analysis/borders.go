@@ -0,0 +1,234 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/nfp"
+)
+
+// IXPTable maps the addresses of the IXP peering LANs to the name of their
+// IXP.
+type IXPTable struct {
+	trie *ds.Trie[string]
+}
+
+// Loads an IXP prefix list file, decompressed if needed.
+func LoadIXPFile(path string) (*IXPTable, error) {
+	in, _, err := nfp.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return LoadIXPTable(in)
+}
+
+// Loads an IXP prefix list, one prefix per line optionally followed by the
+// name of the IXP. Empty lines and lines starting with # are skipped.
+func LoadIXPTable(r io.Reader) (*IXPTable, error) {
+	t := &IXPTable{trie: ds.NewTrie[string]()}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("IXP prefixes line %v: %w", lineNumber, err)
+		}
+		t.trie.Insert(prefix.Masked(), strings.Join(fields[1:], " "))
+	}
+	return t, scanner.Err()
+}
+
+// Returns the number of prefixes in the table.
+func (t *IXPTable) Len() int {
+	return t.trie.Len()
+}
+
+// Returns the name of the IXP whose peering LAN has the address, false if it
+// is not in an IXP prefix. The name is empty if the list has none.
+func (t *IXPTable) Lookup(address netip.Addr) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	_, name, found := t.trie.LongestPrefix(address.Unmap())
+	return name, found
+}
+
+// BorderMethod is how the ASes of an interdomain link were inferred.
+type BorderMethod string
+
+const (
+	// BorderByAddress links have their ASes from the origins of their
+	// addresses.
+	BorderByAddress BorderMethod = "address"
+	// BorderByIXP links have a far address in an IXP peering LAN, the AS of
+	// the far router is the one its own next hops mostly are in.
+	BorderByIXP BorderMethod = "ixp"
+)
+
+// BorderLink is an inferred interdomain link between a near router and a far
+// address.
+type BorderLink struct {
+	Near   netip.Addr
+	Far    netip.Addr
+	NearAS uint32
+	FarAS  uint32
+	Method BorderMethod
+	// Name of the IXP of the far address.
+	IXP string
+	// True if the far router forwards mostly into the AS of the near router,
+	// so its address likely comes from a third AS numbering the link.
+	ThirdParty bool
+	// Destination prefixes routed over the link, in order.
+	Prefixes []netip.Prefix
+	// Number of records supporting the link.
+	Count uint64
+}
+
+// BorderOptions are the inputs of the border inference.
+type BorderOptions struct {
+	AS   ds.ASAnnotator
+	IXPs *IXPTable
+	// Include the links whose far address is likely a third-party address.
+	KeepThirdParty bool
+}
+
+// Infers the interdomain links of the FIB. A near to far edge is a border if
+// the ASes of its ends are known and differ. Far addresses in IXP peering
+// LANs take the AS their router mostly forwards to, if any, and the edges
+// from IXP addresses are skipped. The links are ordered by their near and far
+// addresses.
+func InferBorders(f *ds.FIB, opts BorderOptions) []*BorderLink {
+	successorAS := make(map[netip.Addr]uint32)
+	links := make(map[[2]netip.Addr]*BorderLink)
+
+	f.Walk(func(near netip.Addr, ft *ds.FT) bool {
+		if _, nearIXP := opts.IXPs.Lookup(near); nearIXP {
+			return true
+		}
+		nearAS, found := opts.AS.OriginAS(near)
+		if !found {
+			return true
+		}
+
+		ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+			entry.Walk(func(far netip.Addr, o ds.Observation) bool {
+				key := [2]netip.Addr{near, far}
+				link, found := links[key]
+				if !found {
+					link = newBorderLink(f, opts, near, nearAS, far, successorAS)
+					links[key] = link
+				}
+				if link != nil {
+					link.Prefixes = append(link.Prefixes, prefix)
+					link.Count += o.Count
+				}
+				return true
+			})
+			return true
+		})
+		return true
+	})
+
+	result := make([]*BorderLink, 0, len(links))
+	for _, link := range links {
+		if link != nil && (opts.KeepThirdParty || !link.ThirdParty) {
+			result = append(result, link)
+		}
+	}
+	slices.SortFunc(result, func(a, b *BorderLink) int {
+		return cmp.Or(a.Near.Compare(b.Near), a.Far.Compare(b.Far))
+	})
+	return result
+}
+
+// Creates the link of the edge, nil if it does not cross ASes.
+func newBorderLink(f *ds.FIB, opts BorderOptions, near netip.Addr, nearAS uint32, far netip.Addr, successorAS map[netip.Addr]uint32) *BorderLink {
+	link := &BorderLink{Near: near, Far: far, NearAS: nearAS, Method: BorderByAddress}
+
+	successor, cached := successorAS[far]
+	if !cached {
+		successor = dominantSuccessorAS(f, opts.AS, far)
+		successorAS[far] = successor
+	}
+
+	if name, farIXP := opts.IXPs.Lookup(far); farIXP {
+		link.Method = BorderByIXP
+		link.IXP = name
+		link.FarAS = successor
+	} else {
+		link.FarAS, _ = opts.AS.OriginAS(far)
+		link.ThirdParty = successor == nearAS
+	}
+
+	// The AS of a router in an IXP peering LAN may be unknown, the link is
+	// still interdomain.
+	if (link.FarAS == 0 && link.Method != BorderByIXP) || link.FarAS == nearAS {
+		return nil
+	}
+	return link
+}
+
+// Returns the AS most of the next hops of the router are in, 0 if the router
+// has no forwarding table or its next hops are not announced.
+func dominantSuccessorAS(f *ds.FIB, annotator ds.ASAnnotator, address netip.Addr) uint32 {
+	ft, found := f.GetAddr(address)
+	if !found {
+		return 0
+	}
+
+	counts := make(map[uint32]int)
+	ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+		for _, nextHop := range entry.Addrs() {
+			if as, found := annotator.OriginAS(nextHop); found {
+				counts[as]++
+			}
+		}
+		return true
+	})
+
+	var best uint32
+	for as, count := range counts {
+		if count > counts[best] || (count == counts[best] && as < best) {
+			best = as
+		}
+	}
+	return best
+}
+
+// Writes the inferred links, the prefixes are separated by spaces and the
+// unknown far ASes are written empty.
+func WriteBorders(w io.Writer, links []*BorderLink, format ds.ExportFormat) error {
+	t, err := ds.NewTableWriter(w, format,
+		"near_addr", "far_addr", "near_as", "far_as", "method", "ixp", "third_party",
+		"num_prefixes", "count", "prefixes")
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		prefixes := make([]string, len(link.Prefixes))
+		for i, prefix := range link.Prefixes {
+			prefixes[i] = prefix.String()
+		}
+		var farAS any
+		if link.FarAS != 0 {
+			farAS = link.FarAS
+		}
+		if err := t.Write(link.Near, link.Far, link.NearAS, farAS, string(link.Method), link.IXP, link.ThirdParty,
+			len(link.Prefixes), link.Count, strings.Join(prefixes, " ")); err != nil {
+			return err
+		}
+	}
+	return t.Flush()
+}
@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/ds/dstest"
+)
+
+// testAS maps the addresses of the prefixes to their AS.
+type testAS map[netip.Prefix]uint32
+
+func (a testAS) OriginAS(address netip.Addr) (uint32, bool) {
+	for prefix, as := range a {
+		if prefix.Contains(address) {
+			return as, true
+		}
+	}
+	return 0, false
+}
+
+var testASes = testAS{
+	netip.MustParsePrefix("10.1.0.0/16"): 1,
+	netip.MustParsePrefix("10.2.0.0/16"): 2,
+	netip.MustParsePrefix("10.3.0.0/16"): 3,
+}
+
+// Formats the link on a line for the comparisons.
+func formatBorder(link *BorderLink) string {
+	return fmt.Sprintf("%v %v %v %v %v %q %v %v %v",
+		link.Near, link.Far, link.NearAS, link.FarAS, link.Method, link.IXP, link.ThirdParty, link.Prefixes, link.Count)
+}
+
+func TestInferBorders(t *testing.T) {
+	ixps, err := LoadIXPTable(strings.NewReader("192.0.2.0/24 IX A\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := dstest.NewFIB(
+		// An address border over two prefixes, and an intradomain edge.
+		[3]string{"10.1.0.1", "8.8.8.0/24", "10.2.0.1"},
+		[3]string{"10.1.0.1", "8.8.9.0/24", "10.2.0.1"},
+		[3]string{"10.1.0.1", "8.8.9.0/24", "10.2.0.1"},
+		[3]string{"10.1.0.1", "8.8.8.0/24", "10.1.0.2"},
+		// An IXP far address forwarding into AS 3, its own edges are skipped.
+		[3]string{"10.1.0.1", "9.9.9.0/24", "192.0.2.10"},
+		[3]string{"192.0.2.10", "9.9.9.0/24", "10.3.0.1"},
+		// An IXP far address without a forwarding table.
+		[3]string{"10.1.0.1", "9.9.10.0/24", "192.0.2.20"},
+		// An IXP far address forwarding back into AS 1.
+		[3]string{"10.1.0.1", "9.9.11.0/24", "192.0.2.30"},
+		[3]string{"192.0.2.30", "9.9.11.0/24", "10.1.0.9"},
+		// A far address of AS 3 forwarding back into AS 2, a third party.
+		[3]string{"10.2.0.1", "8.8.8.0/24", "10.3.0.5"},
+		[3]string{"10.3.0.5", "8.8.8.0/24", "10.2.0.9"},
+		[3]string{"10.3.0.5", "8.8.9.0/24", "10.2.0.10"},
+		// Addresses without an AS.
+		[3]string{"172.16.0.1", "8.8.8.0/24", "10.1.0.1"},
+		[3]string{"10.1.0.1", "8.8.10.0/24", "172.16.0.2"},
+	)
+
+	want := []string{
+		`10.1.0.1 10.2.0.1 1 2 address "" false [8.8.8.0/24 8.8.9.0/24] 3`,
+		`10.1.0.1 192.0.2.10 1 3 ixp "IX A" false [9.9.9.0/24] 1`,
+		`10.1.0.1 192.0.2.20 1 0 ixp "IX A" false [9.9.10.0/24] 1`,
+		`10.3.0.5 10.2.0.9 3 2 address "" false [8.8.8.0/24] 1`,
+		`10.3.0.5 10.2.0.10 3 2 address "" false [8.8.9.0/24] 1`,
+	}
+	thirdParty := `10.2.0.1 10.3.0.5 2 3 address "" true [8.8.8.0/24] 1`
+
+	tests := []struct {
+		name string
+		opts BorderOptions
+		want []string
+	}{
+		{"default", BorderOptions{AS: testASes, IXPs: ixps}, want},
+		{"keep third party", BorderOptions{AS: testASes, IXPs: ixps, KeepThirdParty: true},
+			[]string{want[0], want[1], want[2], thirdParty, want[3], want[4]}},
+		{"without IXPs", BorderOptions{AS: testASes},
+			[]string{want[0], want[3], want[4]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := InferBorders(f, tt.opts)
+			got := make([]string, len(links))
+			for i, link := range links {
+				got[i] = formatBorder(link)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLoadIXPTable(t *testing.T) {
+	input := `# IXP peering LANs
+192.0.2.0/24 IX A
+2001:db8::/48	IX  B
+
+198.51.100.7/24
+`
+	ixps, err := LoadIXPTable(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ixps.Len() != 3 {
+		t.Errorf("loaded %v prefixes, want 3", ixps.Len())
+	}
+
+	tests := []struct {
+		address string
+		name    string
+		found   bool
+	}{
+		{"192.0.2.1", "IX A", true},
+		{"::ffff:192.0.2.1", "IX A", true},
+		{"2001:db8::1", "IX B", true},
+		{"198.51.100.1", "", true},
+		{"203.0.113.1", "", false},
+	}
+	for _, tt := range tests {
+		name, found := ixps.Lookup(netip.MustParseAddr(tt.address))
+		if name != tt.name || found != tt.found {
+			t.Errorf("Lookup(%v) = %q, %v, want %q, %v", tt.address, name, found, tt.name, tt.found)
+		}
+	}
+
+	var none *IXPTable
+	if _, found := none.Lookup(netip.MustParseAddr("192.0.2.1")); found {
+		t.Error("a nil table has the address")
+	}
+}
+
+func TestLoadIXPTableErrors(t *testing.T) {
+	_, err := LoadIXPTable(strings.NewReader("192.0.2.0/24\n\n192.0.2.0 IX A\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "IXP prefixes line 3: ") {
+		t.Errorf("got %v, want an error on line 3", err)
+	}
+}
+
+func TestWriteBorders(t *testing.T) {
+	links := []*BorderLink{
+		{
+			Near: netip.MustParseAddr("10.1.0.1"), Far: netip.MustParseAddr("192.0.2.20"), NearAS: 1,
+			Method: BorderByIXP, IXP: "IX A", Count: 2,
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("9.9.9.0/24"), netip.MustParsePrefix("9.9.10.0/24")},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteBorders(&buf, links, ds.ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := `"near_addr","far_addr","near_as","far_as","method","ixp","third_party","num_prefixes","count","prefixes"
+"10.1.0.1","192.0.2.20","1","","ixp","IX A","false","2","2","9.9.9.0/24 9.9.10.0/24"
+`
+	if buf.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", buf.String(), want)
+	}
+
+	if err := WriteBorders(&buf, nil, ds.ExportFormat(-1)); !errors.Is(err, ds.ErrUnknownExportFormat) {
+		t.Errorf("got %v, want %v", err, ds.ErrUnknownExportFormat)
+	}
+}
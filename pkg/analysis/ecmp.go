@@ -0,0 +1,150 @@
+// Package analysis infers the properties of the routers from their forwarding
+// tables.
+package analysis
+
+import (
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// PathClass is how a router forwards a prefix.
+type PathClass int
+
+const (
+	// SinglePath prefixes have a single next hop.
+	SinglePath PathClass = iota
+	// Balanced prefixes have several next hops sharing the destinations evenly,
+	// as a per-destination load balancer does.
+	Balanced
+	// Diverse prefixes have several next hops with an uneven share, like a
+	// route change or a dominant path with a few detours.
+	Diverse
+)
+
+func (c PathClass) String() string {
+	switch c {
+	case SinglePath:
+		return "single-path"
+	case Balanced:
+		return "balanced"
+	default:
+		return "diverse"
+	}
+}
+
+// ECMPOptions are the thresholds of the ECMP analysis.
+type ECMPOptions struct {
+	// Smallest ratio of the least and the most observed next hops of a
+	// balanced prefix.
+	BalanceThreshold float64
+	// Smallest number of prefixes sharing the same next hops for a router to
+	// be considered as balancing over a bundle.
+	MinBundlePrefixes int
+}
+
+// Default thresholds of the ECMP analysis.
+var DefaultECMPOptions = ECMPOptions{
+	BalanceThreshold:  0.5,
+	MinBundlePrefixes: 10,
+}
+
+// Classifies the prefix from the observation counts of its next hops.
+func ClassifyEntry(entry *ds.FTEntry, balanceThreshold float64) PathClass {
+	if entry.Len() <= 1 {
+		return SinglePath
+	}
+
+	var least, most uint64
+	found := false
+	entry.Walk(func(addr netip.Addr, o ds.Observation) bool {
+		if !found || o.Count < least {
+			least, found = o.Count, true
+		}
+		most = max(most, o.Count)
+		return true
+	})
+	if most > 0 && float64(least)/float64(most) >= balanceThreshold {
+		return Balanced
+	}
+	return Diverse
+}
+
+// ECMPReport is the result of the ECMP analysis of a router.
+type ECMPReport struct {
+	Address     netip.Addr
+	NumPrefixes int
+	// Number of prefixes of every class.
+	Classes [3]int
+	FanOut  ds.FanOutHistogram
+	// The most common set of several next hops and the number of prefixes
+	// forwarded to it.
+	Bundle         []netip.Addr
+	BundlePrefixes int
+	// True if the bundle is shared by enough prefixes to be a balanced
+	// bundle rather than a coincidence.
+	LikelyBundle bool
+}
+
+// Analyzes the prefixes of the forwarding table of a router.
+func AnalyzeECMP(address netip.Addr, ft *ds.FT, opts ECMPOptions) ECMPReport {
+	report := ECMPReport{Address: address, NumPrefixes: ft.Len()}
+
+	// Prefixes by their next hop set, only for the prefixes with several
+	// next hops.
+	bundles := make(map[string]int)
+	var bundleKey string
+	ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+		report.Classes[ClassifyEntry(entry, opts.BalanceThreshold)]++
+		report.FanOut.Add(entry.Len())
+		if entry.Len() <= 1 {
+			return true
+		}
+
+		key := entry.String()
+		bundles[key]++
+		// Ties go to the smallest set so the report does not depend on the
+		// walk order.
+		if count := bundles[key]; count > report.BundlePrefixes || (count == report.BundlePrefixes && key < bundleKey) {
+			report.BundlePrefixes = count
+			report.Bundle = entry.Addrs()
+			bundleKey = key
+		}
+		return true
+	})
+
+	report.LikelyBundle = report.BundlePrefixes >= opts.MinBundlePrefixes
+	return report
+}
+
+// Writes the ECMP report of every router.
+func WriteECMP(w io.Writer, f *ds.FIB, exportOptions ds.ExportOptions, opts ECMPOptions) error {
+	t, err := ds.NewTableWriter(w, exportOptions.Format,
+		"address", "num_prefixes", "single_path", "balanced", "diverse", "fan_out",
+		"bundle", "bundle_size", "bundle_prefixes", "likely_bundle")
+	if err != nil {
+		return err
+	}
+
+	f.WalkOrdered(exportOptions.Sorted, func(address netip.Addr, ft *ds.FT) bool {
+		r := AnalyzeECMP(address, ft, opts)
+		err = t.Write(r.Address, r.NumPrefixes, r.Classes[SinglePath], r.Classes[Balanced], r.Classes[Diverse],
+			r.FanOut, joinAddrs(r.Bundle), len(r.Bundle), r.BundlePrefixes, r.LikelyBundle)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return t.Flush()
+}
+
+// Joins the addresses with spaces.
+func joinAddrs(addrs []netip.Addr) string {
+	parts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		parts[i] = addr.String()
+	}
+	return strings.Join(parts, " ")
+}
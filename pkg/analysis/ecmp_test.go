@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"bytes"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// Returns a set of the addresses 10.0.0.i observed the given number of times.
+func entryWithCounts(counts ...uint64) *ds.FTEntry {
+	entry := ds.NewFTEntry()
+	for i, count := range counts {
+		entry.AddCount(netip.AddrFrom4([4]byte{10, 0, 0, byte(i + 1)}), count)
+	}
+	return entry
+}
+
+func TestClassifyEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []uint64
+		want   PathClass
+	}{
+		{"empty", nil, SinglePath},
+		{"single next hop", []uint64{10}, SinglePath},
+		{"even split", []uint64{10, 10}, Balanced},
+		{"at the threshold", []uint64{5, 10}, Balanced},
+		{"below the threshold", []uint64{4, 10}, Diverse},
+		{"one rare next hop", []uint64{10, 10, 1}, Diverse},
+		{"four way balance", []uint64{7, 8, 6, 9}, Balanced},
+		{"never observed next hop first", []uint64{0, 10, 10}, Diverse},
+		{"never observed next hop last", []uint64{10, 10, 0}, Diverse},
+	}
+	for _, tt := range tests {
+		if got := ClassifyEntry(entryWithCounts(tt.counts...), 0.5); got != tt.want {
+			t.Errorf("%v: ClassifyEntry(%v) = %v, want %v", tt.name, tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeECMP(t *testing.T) {
+	ft := ds.NewFowardingTable(true, 24, 48)
+	addr := func(i byte) netip.Addr { return netip.AddrFrom4([4]byte{10, 0, 0, i}) }
+	prefix := func(i byte) netip.Prefix { return netip.PrefixFrom(netip.AddrFrom4([4]byte{8, 8, i, 0}), 24) }
+
+	// Three prefixes over the 1, 2 bundle, two over the 3, 4 one, one with
+	// a single next hop, and one diverse over the 1, 2 bundle.
+	for i := byte(0); i < 3; i++ {
+		ft.InsertPrefix(prefix(i), addr(1))
+		ft.InsertPrefix(prefix(i), addr(2))
+	}
+	for i := byte(3); i < 5; i++ {
+		ft.InsertPrefix(prefix(i), addr(3))
+		ft.InsertPrefix(prefix(i), addr(4))
+	}
+	ft.InsertPrefix(prefix(5), addr(5))
+	ft.InsertObservation(prefix(6), addr(1), ds.Observation{Count: 10})
+	ft.InsertObservation(prefix(6), addr(2), ds.Observation{Count: 1})
+
+	report := AnalyzeECMP(addr(9), ft, ECMPOptions{BalanceThreshold: 0.5, MinBundlePrefixes: 4})
+	if report.NumPrefixes != 7 || report.Classes != [3]int{1, 5, 1} {
+		t.Errorf("prefixes %v, classes %v", report.NumPrefixes, report.Classes)
+	}
+	if !slices.Equal(report.Bundle, []netip.Addr{addr(1), addr(2)}) || report.BundlePrefixes != 4 || !report.LikelyBundle {
+		t.Errorf("bundle %v of %v prefixes, likely %v", report.Bundle, report.BundlePrefixes, report.LikelyBundle)
+	}
+
+	report = AnalyzeECMP(addr(9), ft, ECMPOptions{BalanceThreshold: 0.5, MinBundlePrefixes: 5})
+	if report.LikelyBundle {
+		t.Error("a bundle of 4 prefixes is likely with 5 required")
+	}
+}
+
+func TestAnalyzeECMPTies(t *testing.T) {
+	// Both bundles have a single prefix, the smallest set wins whatever the
+	// walk order.
+	ft := ds.NewFowardingTable(false, 24, 48)
+	ft.InsertPrefix(netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.3"))
+	ft.InsertPrefix(netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.4"))
+	ft.InsertPrefix(netip.MustParsePrefix("9.9.9.0/24"), netip.MustParseAddr("10.0.0.1"))
+	ft.InsertPrefix(netip.MustParsePrefix("9.9.9.0/24"), netip.MustParseAddr("10.0.0.2"))
+
+	report := AnalyzeECMP(netip.MustParseAddr("10.0.0.9"), ft, DefaultECMPOptions)
+	if joinAddrs(report.Bundle) != "10.0.0.1 10.0.0.2" {
+		t.Errorf("bundle %v", report.Bundle)
+	}
+}
+
+func TestWriteECMP(t *testing.T) {
+	f := ds.NewFIB(1, true, 24, 48)
+	near := netip.MustParseAddr("10.0.0.9")
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.1"))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.2"))
+
+	var buf bytes.Buffer
+	if err := WriteECMP(&buf, f, ds.ExportOptions{Format: ds.ExportCSV}, DefaultECMPOptions); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `"10.0.0.9","1","0","1","0",`) || !strings.Contains(lines[1], `"10.0.0.1 10.0.0.2","2","1","false"`) {
+		t.Errorf("unexpected report\n%v", buf.String())
+	}
+}
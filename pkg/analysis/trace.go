@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"io"
+	"net/netip"
+	"slices"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// TraceOutcome is how a simulated path ends.
+type TraceOutcome int
+
+const (
+	// TraceComplete paths reach an address of the destination.
+	TraceComplete TraceOutcome = iota
+	// TraceLoop paths come back to a router already on the path.
+	TraceLoop
+	// TraceDeadEnd paths reach a router without a route to the destination.
+	TraceDeadEnd
+	// TraceTruncated paths are longer than the hop limit.
+	TraceTruncated
+)
+
+func (o TraceOutcome) String() string {
+	switch o {
+	case TraceComplete:
+		return "complete"
+	case TraceLoop:
+		return "loop"
+	case TraceDeadEnd:
+		return "dead_end"
+	default:
+		return "truncated"
+	}
+}
+
+// TraceOptions are the limits of the forwarding simulation.
+type TraceOptions struct {
+	// Largest number of hops of a path.
+	MaxHops int
+	// Largest number of paths from a start router, the load balancers
+	// multiply them.
+	MaxPaths int
+}
+
+// Default limits of the forwarding simulation.
+var DefaultTraceOptions = TraceOptions{
+	MaxHops:  64,
+	MaxPaths: 1000,
+}
+
+// TracePath is a simulated path from a router toward a destination.
+type TracePath struct {
+	Destination netip.Prefix
+	// The routers of the path, the first is the start one. The last is the
+	// repeated router of a loop.
+	Hops    []netip.Addr
+	Outcome TraceOutcome
+	// The routers of the path forwarding the destination to several next
+	// hops, where the path diverges from its siblings.
+	Divergences []netip.Addr
+}
+
+// Simulates the forwarding of the destination from the start router: every
+// router looks up the first address of the destination and forwards to each
+// of its next hops, which look it up in turn. Returns every path in depth
+// first order, up to the limits.
+func Trace(f *ds.FIB, start netip.Addr, destination netip.Prefix, opts TraceOptions) []*TracePath {
+	dst := destination.Addr()
+	var paths []*TracePath
+	var hops, divergences []netip.Addr
+	onPath := make(map[netip.Addr]bool)
+
+	emit := func(outcome TraceOutcome) {
+		paths = append(paths, &TracePath{
+			Destination: destination,
+			Hops:        slices.Clone(hops),
+			Outcome:     outcome,
+			Divergences: slices.Clone(divergences),
+		})
+	}
+
+	var visit func(hop netip.Addr)
+	visit = func(hop netip.Addr) {
+		if len(paths) >= opts.MaxPaths {
+			return
+		}
+		hops = append(hops, hop)
+		defer func() { hops = hops[:len(hops)-1] }()
+
+		switch {
+		case onPath[hop]:
+			emit(TraceLoop)
+			return
+		case destination.Contains(hop):
+			emit(TraceComplete)
+			return
+		case len(hops) > opts.MaxHops:
+			emit(TraceTruncated)
+			return
+		}
+		_, entry, found := f.LookupAddr(hop, dst)
+		if !found || entry.Len() == 0 {
+			emit(TraceDeadEnd)
+			return
+		}
+
+		onPath[hop] = true
+		if entry.Len() > 1 {
+			divergences = append(divergences, hop)
+		}
+		for _, next := range entry.Addrs() {
+			visit(next)
+		}
+		if entry.Len() > 1 {
+			divergences = divergences[:len(divergences)-1]
+		}
+		delete(onPath, hop)
+	}
+
+	visit(start.Unmap())
+	return paths
+}
+
+// Returns the routers with a route to the destination that are not the next
+// hop of another one for it, in order. These are where the measured paths
+// toward the destination enter the FIB. If every router is the next hop of
+// another, as in a loop, all of them are returned.
+func TraceSources(f *ds.FIB, destination netip.Prefix) []netip.Addr {
+	dst := destination.Addr()
+	var routers []netip.Addr
+	nextHops := make(map[netip.Addr]bool)
+	f.Walk(func(address netip.Addr, ft *ds.FT) bool {
+		if _, entry, found := ft.LookupAddr(dst); found {
+			routers = append(routers, address)
+			for _, next := range entry.Addrs() {
+				nextHops[next] = true
+			}
+		}
+		return true
+	})
+
+	sources := make([]netip.Addr, 0, len(routers))
+	for _, address := range routers {
+		if !nextHops[address] {
+			sources = append(sources, address)
+		}
+	}
+	if len(sources) == 0 {
+		sources = routers
+	}
+	slices.SortFunc(sources, netip.Addr.Compare)
+	return sources
+}
+
+// Writes the paths, one row per path. The hops and the divergences are
+// separated by spaces.
+func WriteTraces(w io.Writer, paths []*TracePath, format ds.ExportFormat) error {
+	t, err := ds.NewTableWriter(w, format, "destination", "start", "outcome", "num_hops", "path", "divergences")
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := t.Write(path.Destination, path.Hops[0], path.Outcome.String(), len(path.Hops)-1,
+			joinAddrs(path.Hops), joinAddrs(path.Divergences)); err != nil {
+			return err
+		}
+	}
+	return t.Flush()
+}
@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/ds/dstest"
+)
+
+// A FIB toward 8.8.8.0/24 where 10.0.0.1 splits into a complete path over
+// 10.0.0.2 and 10.0.0.3, which splits again into a loop over 10.0.0.4 and a
+// dead end at 10.0.0.5.
+func testTraceFIB() *ds.FIB {
+	return dstest.NewFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.2", "8.8.8.0/24", "8.8.8.1"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.4"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.5"},
+		[3]string{"10.0.0.4", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.5", "9.9.9.0/24", "10.0.0.6"},
+	)
+}
+
+// Formats the path on a line for the comparisons.
+func formatTrace(path *TracePath) string {
+	return fmt.Sprintf("%v %v %v %v", path.Destination, path.Outcome, path.Hops, path.Divergences)
+}
+
+func TestTrace(t *testing.T) {
+	destination := netip.MustParsePrefix("8.8.8.0/24")
+	tests := []struct {
+		name        string
+		start       string
+		destination netip.Prefix
+		opts        TraceOptions
+		want        []string
+	}{
+		{"every outcome", "10.0.0.1", destination, DefaultTraceOptions, []string{
+			"8.8.8.0/24 complete [10.0.0.1 10.0.0.2 8.8.8.1] [10.0.0.1]",
+			"8.8.8.0/24 loop [10.0.0.1 10.0.0.3 10.0.0.4 10.0.0.3] [10.0.0.1 10.0.0.3]",
+			"8.8.8.0/24 dead_end [10.0.0.1 10.0.0.3 10.0.0.5] [10.0.0.1 10.0.0.3]",
+		}},
+		{"from the middle", "10.0.0.4", destination, DefaultTraceOptions, []string{
+			"8.8.8.0/24 loop [10.0.0.4 10.0.0.3 10.0.0.4] [10.0.0.3]",
+			"8.8.8.0/24 dead_end [10.0.0.4 10.0.0.3 10.0.0.5] [10.0.0.3]",
+		}},
+		{"mapped start", "::ffff:10.0.0.2", destination, DefaultTraceOptions, []string{
+			"8.8.8.0/24 complete [10.0.0.2 8.8.8.1] []",
+		}},
+		{"start in the destination", "8.8.8.1", destination, DefaultTraceOptions, []string{
+			"8.8.8.0/24 complete [8.8.8.1] []",
+		}},
+		{"unknown router", "10.0.0.9", destination, DefaultTraceOptions, []string{
+			"8.8.8.0/24 dead_end [10.0.0.9] []",
+		}},
+		{"no route", "10.0.0.1", netip.MustParsePrefix("2001:db8::/32"), DefaultTraceOptions, []string{
+			"2001:db8::/32 dead_end [10.0.0.1] []",
+		}},
+		{"hop limit", "10.0.0.1", destination, TraceOptions{MaxHops: 1, MaxPaths: 10}, []string{
+			"8.8.8.0/24 truncated [10.0.0.1 10.0.0.2] [10.0.0.1]",
+			"8.8.8.0/24 truncated [10.0.0.1 10.0.0.3] [10.0.0.1]",
+		}},
+		{"path limit", "10.0.0.1", destination, TraceOptions{MaxHops: 64, MaxPaths: 2}, []string{
+			"8.8.8.0/24 complete [10.0.0.1 10.0.0.2 8.8.8.1] [10.0.0.1]",
+			"8.8.8.0/24 loop [10.0.0.1 10.0.0.3 10.0.0.4 10.0.0.3] [10.0.0.1 10.0.0.3]",
+		}},
+	}
+	f := testTraceFIB()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := Trace(f, netip.MustParseAddr(tt.start), tt.destination, tt.opts)
+			got := make([]string, len(paths))
+			for i, path := range paths {
+				got[i] = formatTrace(path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestTraceSources(t *testing.T) {
+	loop := dstest.NewFIB(
+		[3]string{"10.0.0.4", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.4"},
+	)
+	tests := []struct {
+		name        string
+		f           *ds.FIB
+		destination string
+		want        []netip.Addr
+	}{
+		{"entry router", testTraceFIB(), "8.8.8.0/24", []netip.Addr{netip.MustParseAddr("10.0.0.1")}},
+		{"other destination", testTraceFIB(), "9.9.9.9/32", []netip.Addr{netip.MustParseAddr("10.0.0.5")}},
+		{"loop", loop, "8.8.8.0/24", []netip.Addr{netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.4")}},
+		{"no route", testTraceFIB(), "2001:db8::/32", []netip.Addr{}},
+	}
+	for _, tt := range tests {
+		got := TraceSources(tt.f, netip.MustParsePrefix(tt.destination))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%v: TraceSources(%v) = %v, want %v", tt.name, tt.destination, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTraces(t *testing.T) {
+	paths := Trace(testTraceFIB(), netip.MustParseAddr("10.0.0.1"), netip.MustParsePrefix("8.8.8.0/24"), DefaultTraceOptions)
+	var buf bytes.Buffer
+	if err := WriteTraces(&buf, paths, ds.ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := `"destination","start","outcome","num_hops","path","divergences"
+"8.8.8.0/24","10.0.0.1","complete","2","10.0.0.1 10.0.0.2 8.8.8.1","10.0.0.1"
+"8.8.8.0/24","10.0.0.1","loop","3","10.0.0.1 10.0.0.3 10.0.0.4 10.0.0.3","10.0.0.1 10.0.0.3"
+"8.8.8.0/24","10.0.0.1","dead_end","2","10.0.0.1 10.0.0.3 10.0.0.5","10.0.0.1 10.0.0.3"
+`
+	if buf.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", buf.String(), want)
+	}
+}
@@ -0,0 +1,173 @@
+// Package asn maps addresses to their origin autonomous systems, from a CAIDA
+// pfx2as file or a bgpdump text RIB.
+package asn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+	"github.com/ubombar/routeinfo/pkg/nfp"
+)
+
+// Binary MRT dumps are not read, they must be converted to text with bgpdump -m
+// first.
+var ErrBinaryRIB = errors.New("binary MRT dump, convert it with bgpdump -m first")
+
+// Table holds the origin ASes of the announced prefixes. It uses the same
+// trie as the forwarding tables, so an address is mapped to the origins of
+// its longest matching prefix.
+type Table struct {
+	trie *ds.Trie[[]uint32]
+}
+
+// Creates a new empty table.
+func NewTable() *Table {
+	return &Table{trie: ds.NewTrie[[]uint32]()}
+}
+
+// Adds the origins of the prefix, the origins already known are kept.
+func (t *Table) Insert(prefix netip.Prefix, origins ...uint32) {
+	prefix = prefix.Masked()
+	existing, _ := t.trie.Get(prefix)
+	for _, origin := range origins {
+		if !slices.Contains(existing, origin) {
+			existing = append(existing, origin)
+		}
+	}
+	t.trie.Insert(prefix, existing)
+}
+
+// Returns the number of prefixes in the table.
+func (t *Table) Len() int {
+	return t.trie.Len()
+}
+
+// Returns the longest prefix matching the address with its origins.
+func (t *Table) Lookup(address netip.Addr) (netip.Prefix, []uint32, bool) {
+	return t.trie.LongestPrefix(address.Unmap())
+}
+
+// Returns the first origin of the longest prefix matching the address, false
+// if the address is not announced.
+func (t *Table) OriginAS(address netip.Addr) (uint32, bool) {
+	_, origins, found := t.Lookup(address)
+	if !found || len(origins) == 0 {
+		return 0, false
+	}
+	return origins[0], true
+}
+
+// Loads the file, decompressing it if needed, - is stdin. See Load.
+func LoadFile(path string) (*Table, int, error) {
+	in, _, err := nfp.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer in.Close()
+	return Load(in)
+}
+
+// Loads a CAIDA pfx2as file or a bgpdump text RIB (bgpdump -m), the format is
+// detected line by line. Binary MRT dumps are rejected with ErrBinaryRIB.
+// Returns the table and the number of lines that could not be parsed.
+//
+// The pfx2as lines are "address length origins" where the origins of a MOAS
+// prefix are separated by _ and the AS sets by commas. The origin of a RIB
+// entry is the last AS of its path.
+func Load(r io.Reader) (*Table, int, error) {
+	t := NewTable()
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.ContainsRune(line, 0) {
+			return nil, 0, ErrBinaryRIB
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var prefix netip.Prefix
+		var origins []uint32
+		var err error
+		if strings.Contains(line, "|") {
+			prefix, origins, err = parseRIBLine(line)
+		} else {
+			prefix, origins, err = parsePfx2ASLine(line)
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		t.Insert(prefix, origins...)
+	}
+	return t, skipped, scanner.Err()
+}
+
+// Parses a pfx2as line, "1.0.0.0 24 13335" or "1.0.0.0/24 13335".
+func parsePfx2ASLine(line string) (netip.Prefix, []uint32, error) {
+	fields := strings.Fields(line)
+	var prefix netip.Prefix
+	var err error
+	switch len(fields) {
+	case 2:
+		prefix, err = netip.ParsePrefix(fields[0])
+	case 3:
+		prefix, err = netip.ParsePrefix(fields[0] + "/" + fields[1])
+	default:
+		return prefix, nil, fmt.Errorf("expected 2 or 3 fields, got %v", len(fields))
+	}
+	if err != nil {
+		return prefix, nil, err
+	}
+
+	origins, err := parseOrigins(fields[len(fields)-1], "_,")
+	return ds.UnmapPrefix(prefix), origins, err
+}
+
+// Parses a bgpdump -m line, "TABLE_DUMP2|time|B|peer|peer AS|prefix|path|...".
+func parseRIBLine(line string) (netip.Prefix, []uint32, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) < 7 || !strings.HasPrefix(fields[0], "TABLE_DUMP") {
+		return netip.Prefix{}, nil, fmt.Errorf("not a RIB entry")
+	}
+	prefix, err := netip.ParsePrefix(fields[5])
+	if err != nil {
+		return prefix, nil, err
+	}
+
+	path := strings.Fields(fields[6])
+	if len(path) == 0 {
+		return prefix, nil, fmt.Errorf("empty AS path")
+	}
+	origins, err := parseOrigins(strings.Trim(path[len(path)-1], "{}"), ",")
+	return ds.UnmapPrefix(prefix), origins, err
+}
+
+// Parses the AS numbers separated by any of the separators.
+func parseOrigins(field string, separators string) ([]uint32, error) {
+	parts := strings.FieldsFunc(field, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no origin AS")
+	}
+	origins := make([]uint32, len(parts))
+	for i, part := range parts {
+		origin, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(part), "AS"), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		origins[i] = uint32(origin)
+	}
+	return origins, nil
+}
@@ -0,0 +1,122 @@
+package asn
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		prefix  string
+		origins []uint32
+	}{
+		{"pfx2as", "1.0.0.0\t24\t13335", "1.0.0.0/24", []uint32{13335}},
+		{"pfx2as with a slash", "1.0.0.0/24 13335", "1.0.0.0/24", []uint32{13335}},
+		{"pfx2as moas", "1.0.4.0 22 38803_56203", "1.0.4.0/22", []uint32{38803, 56203}},
+		{"pfx2as as set", "1.0.4.0 22 38803,56203", "1.0.4.0/22", []uint32{38803, 56203}},
+		{"pfx2as moas and as set", "1.0.4.0 22 1_2,3", "1.0.4.0/22", []uint32{1, 2, 3}},
+		{"pfx2as AS prefix", "1.0.0.0 24 AS13335", "1.0.0.0/24", []uint32{13335}},
+		{"pfx2as ipv6", "2001:db8:: 32 64500", "2001:db8::/32", []uint32{64500}},
+		{"pfx2as mapped", "::ffff:1.0.0.0 120 13335", "1.0.0.0/24", []uint32{13335}},
+		{"pfx2as not masked", "1.0.0.1 24 13335", "1.0.0.0/24", []uint32{13335}},
+		{"rib", "TABLE_DUMP2|1700000000|B|192.0.2.1|64496|1.0.0.0/24|64496 3356 13335|IGP|192.0.2.1|0|0||NAG||", "1.0.0.0/24", []uint32{13335}},
+		{"rib as set", "TABLE_DUMP2|1700000000|B|192.0.2.1|64496|1.0.4.0/22|64496 3356 {38803,56203}|IGP", "1.0.4.0/22", []uint32{38803, 56203}},
+		{"rib ipv6", "TABLE_DUMP|1700000000|B|2001:db8::1|64496|2001:db8::/32|64496 64500|IGP", "2001:db8::/32", []uint32{64500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, skipped, err := Load(strings.NewReader(tt.line + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped != 0 || table.Len() != 1 {
+				t.Fatalf("got %v prefixes and %v skipped lines, want 1 and 0", table.Len(), skipped)
+			}
+			prefix := netip.MustParsePrefix(tt.prefix)
+			got, origins, found := table.Lookup(prefix.Addr())
+			if !found || got != prefix || !slices.Equal(origins, tt.origins) {
+				t.Errorf("Lookup(%v) = %v, %v, %v, want %v, %v", prefix.Addr(), got, origins, found, prefix, tt.origins)
+			}
+		})
+	}
+}
+
+func TestLoadSkipsBadLines(t *testing.T) {
+	lines := []string{
+		"# a comment",
+		"",
+		"1.0.0.0",
+		"1.0.0.0 24",
+		"1.0.0.0 24 13335 extra",
+		"1.0.0.0 33 13335",
+		"foo 24 13335",
+		"1.0.0.0 24 _",
+		"1.0.0.0 24 AS",
+		"1.0.0.0 24 4294967296",
+		"BGP4MP|1700000000|A|192.0.2.1|64496|1.0.0.0/24|64496 13335|IGP",
+		"TABLE_DUMP2|1700000000|B|192.0.2.1|64496|1.0.0.0/24",
+		"TABLE_DUMP2|1700000000|B|192.0.2.1|64496|1.0.0.0/24||IGP",
+		"TABLE_DUMP2|1700000000|B|192.0.2.1|64496|foo|64496 13335|IGP",
+		"8.8.8.0 24 15169",
+	}
+	table, skipped, err := Load(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 12 {
+		t.Errorf("skipped %v lines, want 12", skipped)
+	}
+	if table.Len() != 1 {
+		t.Errorf("loaded %v prefixes, want 1", table.Len())
+	}
+}
+
+func TestLoadRejectsBinaryMRT(t *testing.T) {
+	// The MRT header of a TABLE_DUMP_V2 PEER_INDEX_TABLE record: timestamp,
+	// type 13, subtype 1 and length, followed by the collector ID.
+	mrt := []byte{0x65, 0x53, 0xf1, 0x00, 0x00, 0x0d, 0x00, 0x01, 0x00, 0x00, 0x00, 0x08, 192, 0, 2, 1, 0, 0, 0, 0}
+	if _, _, err := Load(bytes.NewReader(mrt)); !errors.Is(err, ErrBinaryRIB) {
+		t.Errorf("got %v, want %v", err, ErrBinaryRIB)
+	}
+}
+
+func TestOriginAS(t *testing.T) {
+	input := `1.0.0.0 16 1
+1.0.0.0 24 2_3
+1.0.0.0 24 3_4
+2001:db8:: 32 5
+`
+	table, _, err := Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		origin  uint32
+		found   bool
+	}{
+		{"1.0.0.1", 2, true},
+		{"1.0.1.1", 1, true},
+		{"::ffff:1.0.1.1", 1, true},
+		{"2001:db8::1", 5, true},
+		{"1.1.0.0", 0, false},
+		{"2001:db9::1", 0, false},
+	}
+	for _, tt := range tests {
+		origin, found := table.OriginAS(netip.MustParseAddr(tt.address))
+		if origin != tt.origin || found != tt.found {
+			t.Errorf("OriginAS(%v) = %v, %v, want %v, %v", tt.address, origin, found, tt.origin, tt.found)
+		}
+	}
+
+	if _, origins, _ := table.Lookup(netip.MustParseAddr("1.0.0.1")); !slices.Equal(origins, []uint32{2, 3, 4}) {
+		t.Errorf("the origins of a prefix seen twice are %v, want [2 3 4]", origins)
+	}
+}
@@ -0,0 +1,128 @@
+package ds
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// AggregateResult is the number of prefixes before and after an aggregation.
+type AggregateResult struct {
+	Before int
+	After  int
+}
+
+// Returns the number of prefixes before the aggregation over the number after
+// it, 1 means nothing was merged.
+func (r AggregateResult) Ratio() float64 {
+	if r.After == 0 {
+		return 1
+	}
+	return float64(r.Before) / float64(r.After)
+}
+
+func (r AggregateResult) String() string {
+	return fmt.Sprintf("prefixes=%v aggregated=%v ratio=%.2f", r.Before, r.After, r.Ratio())
+}
+
+// Merges the sibling prefixes with equal next hop sets into their covering
+// supernet, repeatedly, until no siblings can be merged. The merged supernets
+// are merged again with their own siblings, so a /16 fully forwarded to the
+// same far address ends up as a single entry.
+//
+// Lookups are unchanged: siblings are only merged if the supernet does not
+// exist or already has the same next hops, and the more specific prefixes
+// below the siblings are kept. IPv4 prefixes are never merged with the IPv6
+// range around ::ffff:0:0/96.
+func (f *FT) Aggregate() AggregateResult {
+	result := AggregateResult{Before: f.Len()}
+
+	// Prefixes by their length in the 128 bit key space, the longest ones are
+	// merged first so the new supernets are merged in the same pass.
+	var byLength [129][]netip.Prefix
+	f.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+		_, length := keyFromPrefix(prefix)
+		byLength[length] = append(byLength[length], prefix)
+		return true
+	})
+
+	for length := 128; length > 0; length-- {
+		for _, prefix := range byLength[length] {
+			supernet, merged := f.mergeSiblings(prefix)
+			if merged {
+				byLength[length-1] = append(byLength[length-1], supernet)
+			}
+		}
+	}
+
+	result.After = f.Len()
+	return result
+}
+
+// Merges the prefix with its sibling if they have equal next hops. Returns the
+// supernet and true if it was created by the merge.
+func (f *FT) mergeSiblings(prefix netip.Prefix) (netip.Prefix, bool) {
+	// Only the left sibling merges, so every pair is looked at once.
+	supernet, ok := supernetOf(prefix)
+	if !ok || supernet.Addr() != prefix.Addr() {
+		return netip.Prefix{}, false
+	}
+	sibling := siblingOf(prefix)
+	if sibling.Addr().Is4In6() {
+		return netip.Prefix{}, false
+	}
+
+	entry, found := f.get(prefix)
+	if !found {
+		return netip.Prefix{}, false
+	}
+	siblingEntry, found := f.get(sibling)
+	if !found || !entry.Equal(siblingEntry) {
+		return netip.Prefix{}, false
+	}
+
+	supernetEntry, exists := f.get(supernet)
+	if exists && !supernetEntry.Equal(entry) {
+		return netip.Prefix{}, false
+	}
+
+	// The supernet is supported by the records of both siblings.
+	merged := entry.Union(siblingEntry)
+	f.delete(prefix)
+	f.delete(sibling)
+	if exists {
+		f.insert(supernet, supernetEntry.Union(merged))
+		return netip.Prefix{}, false
+	}
+	f.insert(supernet, merged)
+	return supernet, true
+}
+
+// Merges the sibling prefixes of every forwarding table, see FT.Aggregate.
+func (f *FIB) Aggregate() AggregateResult {
+	var result AggregateResult
+	for _, ft := range f.fibs {
+		r := ft.Aggregate()
+		result.Before += r.Before
+		result.After += r.After
+	}
+	return result
+}
+
+// Returns the prefix one bit shorter covering the given one, false for the
+// IPv4 and IPv6 default routes.
+func supernetOf(prefix netip.Prefix) (netip.Prefix, bool) {
+	if prefix.Bits() <= 0 {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(prefix.Addr(), prefix.Bits()-1).Masked(), true
+}
+
+// Returns the other half of the supernet of the prefix, the prefix must not
+// be a default route.
+func siblingOf(prefix netip.Prefix) netip.Prefix {
+	b := prefix.Addr().AsSlice()
+	i := prefix.Bits() - 1
+	b[i/8] ^= 0x80 >> (i % 8)
+	address, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(address, prefix.Bits())
+}
@@ -0,0 +1,158 @@
+package ds
+
+import (
+	"math/rand"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// A FIB with siblings to merge, up to a second level, a nested prefix, pairs
+// that must not merge, an IPv6 pair and the IPv4 halves next to the IPv6
+// range around ::ffff:0:0/96.
+func testAggregateFIB() *FIB {
+	return testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/25", "10.0.0.10"},
+		[3]string{"10.0.0.1", "8.8.8.128/25", "10.0.0.10"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.10"},
+		[3]string{"10.0.0.1", "8.8.8.0/26", "10.0.0.11"},
+		[3]string{"10.0.0.1", "9.9.8.0/24", "10.0.0.12"},
+		[3]string{"10.0.0.1", "9.9.9.0/24", "10.0.0.12"},
+		[3]string{"10.0.0.1", "9.9.8.0/23", "10.0.0.12"},
+		[3]string{"10.0.0.1", "7.7.6.0/24", "10.0.0.12"},
+		[3]string{"10.0.0.1", "7.7.7.0/24", "10.0.0.12"},
+		[3]string{"10.0.0.1", "7.7.6.0/23", "10.0.0.13"},
+		[3]string{"10.0.0.1", "6.6.6.0/24", "10.0.0.10"},
+		[3]string{"10.0.0.1", "6.6.7.0/24", "10.0.0.11"},
+		[3]string{"10.0.0.1", "5.5.5.0/24", "10.0.0.10"},
+		[3]string{"10.0.0.1", "5.5.6.0/24", "10.0.0.10"},
+		[3]string{"10.0.0.1", "2001:db8::/49", "10.0.0.10"},
+		[3]string{"10.0.0.1", "2001:db8:0:8000::/49", "10.0.0.10"},
+		[3]string{"10.0.0.2", "0.0.0.0/1", "10.0.0.14"},
+		[3]string{"10.0.0.2", "128.0.0.0/1", "10.0.0.14"},
+		[3]string{"10.0.0.2", "::fffe:0:0/96", "10.0.0.14"},
+	)
+}
+
+func TestAggregate(t *testing.T) {
+	f := testAggregateFIB()
+	result := f.Aggregate()
+	if result != (AggregateResult{Before: 19, After: 13}) {
+		t.Errorf("got %+v, want 19 prefixes before and 13 after", result)
+	}
+
+	want := `"near_addr","prefix","far_addr","count","first_seen","last_seen","first_round","last_round"
+"10.0.0.1","5.5.5.0/24","10.0.0.10","1","","","",""
+"10.0.0.1","5.5.6.0/24","10.0.0.10","1","","","",""
+"10.0.0.1","6.6.6.0/24","10.0.0.10","1","","","",""
+"10.0.0.1","6.6.7.0/24","10.0.0.11","1","","","",""
+"10.0.0.1","7.7.6.0/23","10.0.0.13","1","","","",""
+"10.0.0.1","7.7.6.0/24","10.0.0.12","1","","","",""
+"10.0.0.1","7.7.7.0/24","10.0.0.12","1","","","",""
+"10.0.0.1","8.8.8.0/23","10.0.0.10","3","","","",""
+"10.0.0.1","8.8.8.0/26","10.0.0.11","1","","","",""
+"10.0.0.1","9.9.8.0/23","10.0.0.12","3","","","",""
+"10.0.0.1","2001:db8::/48","10.0.0.10","2","","","",""
+"10.0.0.2","::fffe:0:0/96","10.0.0.14","1","","","",""
+"10.0.0.2","0.0.0.0/0","10.0.0.14","2","","","",""
+`
+	if got := exportString(t, f); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+
+	// Nothing is left to merge.
+	if again := f.Aggregate(); again != (AggregateResult{Before: 13, After: 13}) {
+		t.Errorf("a second aggregation gives %+v", again)
+	}
+}
+
+// Returns the next hops of the destination for every router, nil if there is
+// no route.
+func lookupAll(f *FIB, routers []netip.Addr, dst netip.Addr) [][]netip.Addr {
+	nextHops := make([][]netip.Addr, len(routers))
+	for i, router := range routers {
+		if _, entry, found := f.LookupAddr(router, dst); found {
+			nextHops[i] = entry.Addrs()
+		}
+	}
+	return nextHops
+}
+
+func TestAggregateKeepsLookups(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	routers := []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")}
+	nextHops := []netip.Addr{netip.MustParseAddr("10.1.0.1"), netip.MustParseAddr("10.1.0.2")}
+
+	for _, optimizeForIPv4 := range []bool{false, true} {
+		// Prefixes around a few networks so many of them are siblings or
+		// nested, with few next hops so many of them are equal.
+		var prefixes []netip.Prefix
+		for i := 0; i < 2000; i++ {
+			var prefix netip.Prefix
+			if r.Intn(3) == 0 {
+				address := netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 5: byte(r.Intn(4)), 6: byte(r.Intn(256))})
+				prefix = netip.PrefixFrom(address, 40+r.Intn(25)).Masked()
+			} else {
+				address := netip.AddrFrom4([4]byte{8, byte(r.Intn(4)), byte(r.Intn(256)), byte(r.Intn(256))})
+				prefix = netip.PrefixFrom(address, 14+r.Intn(13)).Masked()
+			}
+			prefixes = append(prefixes, prefix)
+		}
+
+		build := func() *FIB {
+			f := NewFIB(2, optimizeForIPv4, 24, 48)
+			for i, prefix := range prefixes {
+				f.InsertPrefix(routers[i%2], prefix, nextHops[i%3%2])
+				if i%7 == 0 {
+					f.InsertPrefix(routers[i%2], prefix, nextHops[(i+1)%2])
+				}
+			}
+			return f
+		}
+		before, after := build(), build()
+		result := after.Aggregate()
+		if result.Before != before.Summary().Prefixes || result.After != after.Summary().Prefixes {
+			t.Errorf("optimize=%v: got %+v, the FIB has %v prefixes after", optimizeForIPv4, result, after.Summary().Prefixes)
+		}
+		if result.After >= result.Before {
+			t.Errorf("optimize=%v: nothing was merged, %+v", optimizeForIPv4, result)
+		}
+
+		// The first, last and random addresses of every prefix, and random
+		// addresses around them.
+		var sample []netip.Addr
+		for _, prefix := range prefixes {
+			sample = append(sample, prefix.Addr(), lastAddr(prefix), randomAddrIn(r, prefix))
+		}
+		for i := 0; i < 2000; i++ {
+			sample = append(sample, netip.AddrFrom4([4]byte{8, byte(r.Intn(5)), byte(r.Intn(256)), byte(r.Intn(256))}))
+		}
+		for _, dst := range sample {
+			if got, want := lookupAll(after, routers, dst), lookupAll(before, routers, dst); !slices.EqualFunc(got, want, slices.Equal) {
+				t.Fatalf("optimize=%v: %v goes to %v after the aggregation, want %v", optimizeForIPv4, dst, got, want)
+			}
+		}
+	}
+}
+
+// Returns the last address of the prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	address, _ := netip.AddrFromSlice(b)
+	return address
+}
+
+// Returns a random address of the prefix.
+func randomAddrIn(r *rand.Rand, prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		if r.Intn(2) == 0 {
+			b[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	address, _ := netip.AddrFromSlice(b)
+	return address
+}
@@ -0,0 +1,306 @@
+package ds
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// AliasSet is the interface addresses of a router, ordered.
+type AliasSet struct {
+	// Identifier of the router in the alias file, empty if it has none.
+	ID    string
+	Addrs []netip.Addr
+}
+
+// AliasSets groups the interface addresses by router. An address belongs to
+// at most one router.
+type AliasSets struct {
+	sets   []AliasSet
+	router map[netip.Addr]int
+}
+
+// Creates an empty alias set collection.
+func NewAliasSets() *AliasSets {
+	return &AliasSets{router: make(map[netip.Addr]int)}
+}
+
+// Adds the router with its interface addresses. The addresses already added
+// to another router are skipped, the number of them is returned.
+func (a *AliasSets) Add(id string, addrs ...netip.Addr) int {
+	set := AliasSet{ID: id}
+	skipped := 0
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if i, found := a.router[addr]; found {
+			if i != len(a.sets) {
+				skipped++
+			}
+			continue
+		}
+		a.router[addr] = len(a.sets)
+		set.Addrs = append(set.Addrs, addr)
+	}
+	if len(set.Addrs) == 0 {
+		return skipped
+	}
+	slices.SortFunc(set.Addrs, netip.Addr.Compare)
+	a.sets = append(a.sets, set)
+	return skipped
+}
+
+// Returns the number of routers.
+func (a *AliasSets) Len() int {
+	return len(a.sets)
+}
+
+// Returns the routers in the order they were added.
+func (a *AliasSets) Sets() []AliasSet {
+	return a.sets
+}
+
+// Returns the router of the interface address.
+func (a *AliasSets) Router(address netip.Addr) (AliasSet, bool) {
+	i, found := a.router[address.Unmap()]
+	if !found {
+		return AliasSet{}, false
+	}
+	return a.sets[i], true
+}
+
+// Returns the smallest address of the router of the interface, or the address
+// itself if it is not in an alias set.
+func (a *AliasSets) Representative(address netip.Addr) netip.Addr {
+	address = address.Unmap()
+	if i, found := a.router[address]; found {
+		return a.sets[i].Addrs[0]
+	}
+	return address
+}
+
+// Loads the alias sets, one router per line with its addresses separated by
+// spaces or commas. The lines may start with the router identifier as in the
+// ITDK nodes files, "node N1:  192.0.2.1 198.51.100.7". Empty lines and lines
+// starting with # are skipped. An address already in the set of a previous
+// line is kept there, the number of such addresses is returned.
+func LoadAliases(r io.Reader) (*AliasSets, int, error) {
+	a := NewAliasSets()
+	duplicates := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var id string
+		if rest, found := strings.CutPrefix(line, "node "); found {
+			id, line, found = strings.Cut(rest, ":")
+			if !found {
+				return nil, 0, fmt.Errorf("aliases line %v: missing : after the node identifier", lineNumber)
+			}
+			id = strings.TrimSpace(id)
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		addrs := make([]netip.Addr, 0, len(fields))
+		for _, field := range fields {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, 0, fmt.Errorf("aliases line %v: %w", lineNumber, err)
+			}
+			addrs = append(addrs, addr)
+		}
+		duplicates += a.Add(id, addrs...)
+	}
+	return a, duplicates, scanner.Err()
+}
+
+// Writes the alias sets in the format read by LoadAliases.
+func (a *AliasSets) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, set := range a.sets {
+		if set.ID != "" {
+			fmt.Fprintf(bw, "node %v:  ", set.ID)
+		}
+		for i, addr := range set.Addrs {
+			if i > 0 {
+				bw.WriteByte(' ')
+			}
+			bw.WriteString(addr.String())
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// CollapseOptions configures how a FIB is collapsed into routers.
+type CollapseOptions struct {
+	// Replaces the far addresses by the representative of their router too.
+	MapFarAddresses bool
+}
+
+// AliasConflict is a prefix the interfaces of a router forward to different
+// next hops.
+type AliasConflict struct {
+	Router AliasSet
+	Prefix netip.Prefix
+	// The interfaces having the prefix, with their next hops.
+	Interfaces []netip.Addr
+	NextHops   []*FTEntry
+	// True if no next hop is shared by all the interfaces.
+	Disjoint bool
+}
+
+// Returns a FIB keyed by router instead of interface. The routers are keyed
+// by their node identifier when the alias file has one, and by the smallest
+// address of their alias set otherwise. The tables of the interfaces of a
+// router are merged into the one of that address, see RouterID, and the far
+// addresses are mapped the same way if asked. The prefixes the interfaces of
+// a router forward to different next hops are returned as conflicts, ordered
+// by router and prefix. The FIB is not modified.
+func (f *FIB) CollapseAliases(aliases *AliasSets, opts CollapseOptions) (*FIB, []*AliasConflict) {
+	collapsed := NewFIB(uint(len(f.fibs)), f.optimizeForIPv4, f.ipv4PrefixLength, f.ipv6PrefixLength)
+	collapsed.farRouters = opts.MapFarAddresses
+	keyRouter := func(address netip.Addr) {
+		if set, found := aliases.Router(address); found && set.ID != "" {
+			collapsed.setRouterID(address, set.ID)
+		}
+	}
+	mapEntry := func(entry *FTEntry) *FTEntry {
+		if !opts.MapFarAddresses {
+			return entry.Clone()
+		}
+		mapped := newFTEntry(uint(entry.Len()))
+		entry.walk(func(addr netip.Addr, o observation) bool {
+			representative := aliases.Representative(addr)
+			keyRouter(representative)
+			mapped.add(representative, o)
+			return true
+		})
+		return mapped
+	}
+
+	for address, ft := range f.fibs {
+		if set, found := aliases.Router(address); found && len(set.Addrs) > 1 {
+			continue
+		}
+		keyRouter(address)
+		table := collapsed.table(address)
+		ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+			table.insert(prefix, mapEntry(entry))
+			return true
+		})
+	}
+
+	var conflicts []*AliasConflict
+	for _, set := range aliases.sets {
+		if len(set.Addrs) < 2 {
+			continue
+		}
+
+		prefixes := make(map[netip.Prefix]*AliasConflict)
+		for _, address := range set.Addrs {
+			ft, found := f.fibs[address]
+			if !found {
+				continue
+			}
+			ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+				c, found := prefixes[prefix]
+				if !found {
+					c = &AliasConflict{Router: set, Prefix: prefix}
+					prefixes[prefix] = c
+				}
+				c.Interfaces = append(c.Interfaces, address)
+				c.NextHops = append(c.NextHops, mapEntry(entry))
+				return true
+			})
+		}
+		if len(prefixes) == 0 {
+			continue
+		}
+
+		keyRouter(set.Addrs[0])
+		table := collapsed.table(set.Addrs[0])
+		for prefix, c := range prefixes {
+			merged := c.NextHops[0].Clone()
+			common := c.NextHops[0]
+			agree := true
+			for _, entry := range c.NextHops[1:] {
+				agree = agree && entry.Equal(c.NextHops[0])
+				merged.Merge(entry)
+				common = common.Intersect(entry)
+			}
+			table.insert(prefix, merged)
+			if !agree {
+				c.Disjoint = common.Len() == 0
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	slices.SortFunc(conflicts, func(a, b *AliasConflict) int {
+		return cmp.Or(a.Router.Addrs[0].Compare(b.Router.Addrs[0]), a.Prefix.Addr().Compare(b.Prefix.Addr()), a.Prefix.Bits()-b.Prefix.Bits())
+	})
+	return collapsed, conflicts
+}
+
+// Returns the key of the router of the address, its node identifier if the
+// FIB was collapsed from an alias file giving one, the address otherwise.
+func (f *FIB) RouterID(address netip.Addr) string {
+	address = address.Unmap()
+	if id, found := f.routerIDs[address]; found {
+		return id
+	}
+	return address.String()
+}
+
+func (f *FIB) setRouterID(address netip.Addr, id string) {
+	if f.routerIDs == nil {
+		f.routerIDs = make(map[netip.Addr]string)
+	}
+	f.routerIDs[address] = id
+}
+
+// Returns the router key of the near address for the writers, the address
+// itself unless it has a node identifier.
+func (f *FIB) nearKey(address netip.Addr) any {
+	if id, found := f.routerIDs[address]; found {
+		return id
+	}
+	return address
+}
+
+// Same as nearKey for the far addresses, which are only router keys if they
+// were mapped to their router.
+func (f *FIB) farKey(address netip.Addr) any {
+	if !f.farRouters {
+		return address
+	}
+	return f.nearKey(address)
+}
+
+// Writes the conflicts, one row per interface of every conflicting prefix.
+func WriteAliasConflicts(w io.Writer, conflicts []*AliasConflict, format ExportFormat) error {
+	t, err := NewTableWriter(w, format, "router_id", "router", "prefix", "interface", "next_hops", "disjoint")
+	if err != nil {
+		return err
+	}
+	for _, c := range conflicts {
+		for i, address := range c.Interfaces {
+			if err := t.Write(c.Router.ID, c.Router.Addrs[0], c.Prefix, address, c.NextHops[i].String(), c.Disjoint); err != nil {
+				return err
+			}
+		}
+	}
+	return t.Flush()
+}
@@ -0,0 +1,259 @@
+package ds
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Writes the alias sets as LoadAliases reads them.
+func aliasesString(t *testing.T, a *AliasSets) string {
+	t.Helper()
+	var sb strings.Builder
+	if err := a.Write(&sb); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestLoadAliases(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"spaces", "10.0.0.2 10.0.0.1\n10.0.1.1\n", "10.0.0.1 10.0.0.2\n10.0.1.1\n"},
+		{"commas and tabs", "10.0.0.1,10.0.0.2\t10.0.0.3, 10.0.0.4\n", "10.0.0.1 10.0.0.2 10.0.0.3 10.0.0.4\n"},
+		{"comments and empty lines", "# routers\n\n  10.0.0.1 10.0.0.2  \r\n", "10.0.0.1 10.0.0.2\n"},
+		{"itdk nodes", "# ITDK\nnode N1:  10.0.0.2 10.0.0.1 \nnode N22: 2001:db8::1\n", "node N1:  10.0.0.1 10.0.0.2\nnode N22:  2001:db8::1\n"},
+		{"mapped addresses", "::ffff:10.0.0.1 10.0.0.2\n", "10.0.0.1 10.0.0.2\n"},
+		{"node without addresses", "node N1:\n10.0.0.1\n", "10.0.0.1\n"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, err := LoadAliases(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := aliasesString(t, a); got != tt.want {
+				t.Errorf("got\n%v\nwant\n%v", got, tt.want)
+			}
+
+			// The written sets load back to the same sets.
+			b, _, err := LoadAliases(strings.NewReader(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := aliasesString(t, b); got != tt.want {
+				t.Errorf("round trip got\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAliasesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		// Start of the error, the rest comes from netip.
+		err string
+	}{
+		{"missing colon", "10.0.0.1\nnode N1 10.0.0.2\n", "aliases line 2: missing : after the node identifier"},
+		{"bad address", "# routers\n\n10.0.0.1 10.0.0.256\n", `aliases line 3: ParseAddr("10.0.0.256")`},
+		{"prefix", "10.0.0.0/24\n", `aliases line 1: ParseAddr("10.0.0.0/24")`},
+		{"bad address after a node", "node N1:  10.0.0.1 foo\n", `aliases line 1: ParseAddr("foo")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, err := LoadAliases(strings.NewReader(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) || a != nil {
+				t.Errorf("got %v, %v, want the error %q", a, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAliasSetsLookups(t *testing.T) {
+	a, _, err := LoadAliases(strings.NewReader("node N1:  10.0.0.2 10.0.0.1\n10.0.1.1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Len() != 2 {
+		t.Errorf("loaded %v routers, want 2", a.Len())
+	}
+
+	tests := []struct {
+		address        string
+		id             string
+		found          bool
+		representative string
+	}{
+		{"10.0.0.2", "N1", true, "10.0.0.1"},
+		{"::ffff:10.0.0.2", "N1", true, "10.0.0.1"},
+		{"10.0.0.1", "N1", true, "10.0.0.1"},
+		{"10.0.1.1", "", true, "10.0.1.1"},
+		{"10.0.2.1", "", false, "10.0.2.1"},
+	}
+	for _, tt := range tests {
+		address := netip.MustParseAddr(tt.address)
+		set, found := a.Router(address)
+		if set.ID != tt.id || found != tt.found {
+			t.Errorf("Router(%v) = %+v, %v, want the ID %q, %v", address, set, found, tt.id, tt.found)
+		}
+		if got := a.Representative(address).String(); got != tt.representative {
+			t.Errorf("Representative(%v) = %v, want %v", address, got, tt.representative)
+		}
+	}
+}
+
+// The aliases of the collapse tests, the last line repeats an address of N1.
+const testAliases = `node N1:  10.0.0.1 10.0.0.2 10.0.0.3
+node N2:  10.0.1.1 10.0.1.2
+10.0.0.3 10.0.2.1
+`
+
+// A FIB where the interfaces of N1 agree on 8.8.8.0/24, forward 8.8.9.0/24
+// to different interfaces of N2, and partly agree on 8.8.10.0/24.
+func testCollapseFIB() *FIB {
+	return testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.1.1"},
+		[3]string{"10.0.0.2", "8.8.8.0/24", "10.0.1.1"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.1.1"},
+		[3]string{"10.0.0.2", "8.8.9.0/24", "10.0.1.2"},
+		[3]string{"10.0.0.1", "8.8.10.0/24", "10.0.1.1"},
+		[3]string{"10.0.0.3", "8.8.10.0/24", "10.0.1.1"},
+		[3]string{"10.0.0.3", "8.8.10.0/24", "10.0.9.9"},
+		[3]string{"10.0.1.2", "8.8.8.0/24", "10.0.5.5"},
+		[3]string{"10.0.2.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.9.9.9", "8.8.8.0/24", "10.0.0.3"},
+	)
+}
+
+// Formats the conflict on a line for the comparisons.
+func formatConflict(c *AliasConflict) string {
+	return fmt.Sprintf("%v %v %v %v %v %v", c.Router.ID, c.Router.Addrs[0], c.Prefix, c.Interfaces, c.NextHops, c.Disjoint)
+}
+
+func TestAliasDuplicates(t *testing.T) {
+	a, duplicates, err := LoadAliases(strings.NewReader(testAliases + "10.0.0.4 10.0.0.4\n10.0.1.1 10.0.1.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The repeated 10.0.0.3 and the line of the N2 addresses, the repeat on
+	// the same line is not counted.
+	if duplicates != 3 {
+		t.Errorf("got %v duplicates, want 3", duplicates)
+	}
+	want := "node N1:  10.0.0.1 10.0.0.2 10.0.0.3\nnode N2:  10.0.1.1 10.0.1.2\n10.0.2.1\n10.0.0.4\n"
+	if got := aliasesString(t, a); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+	if set, _ := a.Router(netip.MustParseAddr("10.0.0.3")); set.ID != "N1" {
+		t.Errorf("10.0.0.3 moved to the router %+v, want N1", set)
+	}
+}
+
+func TestCollapseAliases(t *testing.T) {
+	aliases, _, err := LoadAliases(strings.NewReader(testAliases))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      CollapseOptions
+		want      string
+		conflicts []string
+	}{
+		{"interfaces", CollapseOptions{}, `"near_addr","prefix","far_addr","count","first_seen","last_seen","first_round","last_round"
+"N1","8.8.8.0/24","10.0.1.1","2","","","",""
+"N1","8.8.9.0/24","10.0.1.1","1","","","",""
+"N1","8.8.9.0/24","10.0.1.2","1","","","",""
+"N1","8.8.10.0/24","10.0.1.1","2","","","",""
+"N1","8.8.10.0/24","10.0.9.9","1","","","",""
+"N2","8.8.8.0/24","10.0.5.5","1","","","",""
+"10.0.2.1","8.8.8.0/24","10.0.0.2","1","","","",""
+"10.9.9.9","8.8.8.0/24","10.0.0.3","1","","","",""
+`, []string{
+			"N1 10.0.0.1 8.8.9.0/24 [10.0.0.1 10.0.0.2] [{10.0.1.1} {10.0.1.2}] true",
+			"N1 10.0.0.1 8.8.10.0/24 [10.0.0.1 10.0.0.3] [{10.0.1.1} {10.0.1.1, 10.0.9.9}] false",
+		}},
+		{"far addresses", CollapseOptions{MapFarAddresses: true}, `"near_addr","prefix","far_addr","count","first_seen","last_seen","first_round","last_round"
+"N1","8.8.8.0/24","N2","2","","","",""
+"N1","8.8.9.0/24","N2","2","","","",""
+"N1","8.8.10.0/24","N2","2","","","",""
+"N1","8.8.10.0/24","10.0.9.9","1","","","",""
+"N2","8.8.8.0/24","10.0.5.5","1","","","",""
+"10.0.2.1","8.8.8.0/24","N1","1","","","",""
+"10.9.9.9","8.8.8.0/24","N1","1","","","",""
+`, []string{
+			"N1 10.0.0.1 8.8.10.0/24 [10.0.0.1 10.0.0.3] [{10.0.1.1} {10.0.1.1, 10.0.9.9}] false",
+		}},
+	}
+	f := testCollapseFIB()
+	before := exportString(t, f)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collapsed, conflicts := f.CollapseAliases(aliases, tt.opts)
+			if got := exportString(t, collapsed); got != tt.want {
+				t.Errorf("got\n%v\nwant\n%v", got, tt.want)
+			}
+			got := make([]string, len(conflicts))
+			for i, c := range conflicts {
+				got[i] = formatConflict(c)
+			}
+			if !slices.Equal(got, tt.conflicts) {
+				t.Errorf("got the conflicts\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.conflicts, "\n"))
+			}
+			if exportString(t, f) != before {
+				t.Error("CollapseAliases modified the FIB")
+			}
+			// The routers with an identifier are keyed by it, the others by
+			// their smallest address.
+			for address, want := range map[string]string{"10.0.0.1": "N1", "::ffff:10.0.0.1": "N1", "10.0.1.1": "N2", "10.0.2.1": "10.0.2.1", "10.9.9.9": "10.9.9.9"} {
+				if got := collapsed.RouterID(netip.MustParseAddr(address)); got != want {
+					t.Errorf("RouterID(%v) = %v, want %v", address, got, want)
+				}
+			}
+			if got := f.RouterID(netip.MustParseAddr("10.0.0.1")); got != "10.0.0.1" {
+				t.Errorf("the FIB that was not collapsed keys 10.0.0.1 by %v", got)
+			}
+
+			var buf bytes.Buffer
+			if err := collapsed.Save(&buf, nil); err != nil {
+				t.Fatal(err)
+			}
+			loaded, _, err := Load(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := exportString(t, loaded); got != tt.want {
+				t.Errorf("the loaded snapshot exports\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAliasConflicts(t *testing.T) {
+	aliases, _, err := LoadAliases(strings.NewReader(testAliases))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, conflicts := testCollapseFIB().CollapseAliases(aliases, CollapseOptions{MapFarAddresses: true})
+
+	var sb strings.Builder
+	if err := WriteAliasConflicts(&sb, conflicts, ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := `"router_id","router","prefix","interface","next_hops","disjoint"
+"N1","10.0.0.1","8.8.10.0/24","10.0.0.1","{10.0.1.1}","false"
+"N1","10.0.0.1","8.8.10.0/24","10.0.0.3","{10.0.1.1, 10.0.9.9}","false"
+`
+	if sb.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", sb.String(), want)
+	}
+}
@@ -0,0 +1,111 @@
+package ds
+
+import (
+	"flag"
+	"math/rand"
+	"net/netip"
+	"runtime"
+	"testing"
+)
+
+// The number of records the benchmarks build from, set with
+// go test -bench . ./pkg/ds -bench-records 200000 for a quicker run.
+var benchRecordCount = flag.Int("bench-records", 10_000_000, "number of synthetic records the FIB benchmarks build from")
+
+// benchRecord is a synthetic near, destination and far address triple.
+type benchRecord struct {
+	near, dst, far netip.Addr
+}
+
+// Generates the synthetic records. Every router has a few far addresses and
+// forwards random destinations to them.
+func benchRecords(n int, routers int, ipv6Fraction float64) []benchRecord {
+	r := rand.New(rand.NewSource(1))
+	records := make([]benchRecord, n)
+	for i := range records {
+		router := uint32(r.Intn(routers))
+		near := netip.AddrFrom4([4]byte{10, byte(router >> 16), byte(router >> 8), byte(router)})
+		far := netip.AddrFrom4([4]byte{11, byte(router >> 8), byte(router), byte(r.Intn(4))})
+
+		var dst netip.Addr
+		if r.Float64() < ipv6Fraction {
+			var b [16]byte
+			b[0], b[1] = 0x20, 0x01
+			r.Read(b[2:8])
+			dst = netip.AddrFrom16(b)
+		} else {
+			dst = netip.AddrFrom4([4]byte{byte(1 + r.Intn(223)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256))})
+		}
+		records[i] = benchRecord{near: near, dst: dst, far: far}
+	}
+	return records
+}
+
+// Builds a FIB from the records.
+func buildBenchFIB(records []benchRecord, routers int, optimizeForIPv4 bool) *FIB {
+	f := NewFIB(uint(routers), optimizeForIPv4, 24, 48)
+	for _, record := range records {
+		f.InsertAddr(record.near, record.dst, record.far)
+	}
+	return f
+}
+
+// Builds the FIB b.N times and reports the records per second and the heap
+// bytes per prefix of the last one.
+func benchmarkBuild(b *testing.B, optimizeForIPv4 bool) {
+	const routers = 1000
+	records := benchRecords(*benchRecordCount, routers, 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var f *FIB
+	for i := 0; i < b.N; i++ {
+		f = buildBenchFIB(records, routers, optimizeForIPv4)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(len(records)*b.N)/b.Elapsed().Seconds(), "records/s")
+
+	f = nil
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f = buildBenchFIB(records, routers, optimizeForIPv4)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(f.Summary().Prefixes), "heap-bytes/prefix")
+	}
+	runtime.KeepAlive(f)
+}
+
+func BenchmarkBuildIPv4Fast(b *testing.B) {
+	benchmarkBuild(b, true)
+}
+
+func BenchmarkBuildDualStack(b *testing.B) {
+	benchmarkBuild(b, false)
+}
+
+func BenchmarkLookup(b *testing.B) {
+	const routers = 1000
+	records := benchRecords(*benchRecordCount, routers, 0.2)
+	queries := benchRecords(10_000, routers, 0.2)
+
+	for _, bench := range []struct {
+		name            string
+		optimizeForIPv4 bool
+	}{
+		{"IPv4Fast", true},
+		{"DualStack", false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			f := buildBenchFIB(records, routers, bench.optimizeForIPv4)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				query := queries[i%len(queries)]
+				f.LookupAddr(query.near, query.dst)
+			}
+		})
+	}
+}
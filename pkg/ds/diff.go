@@ -0,0 +1,159 @@
+package ds
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// ChangeKind is the kind of a difference between two FIBs.
+type ChangeKind string
+
+const (
+	RouterAdded   ChangeKind = "router_added"
+	RouterRemoved ChangeKind = "router_removed"
+	// RouterChanged is written after the prefix changes of a router present
+	// in both FIBs, with its churn.
+	RouterChanged ChangeKind = "router_changed"
+	PrefixAdded   ChangeKind = "prefix_added"
+	PrefixRemoved ChangeKind = "prefix_removed"
+	// PrefixChanged is a prefix whose next hops changed.
+	PrefixChanged ChangeKind = "prefix_changed"
+)
+
+// Change is a difference between two FIBs. The prefix is only set for the
+// prefix changes and the churn only for the router changes.
+type Change struct {
+	Kind   ChangeKind    `json:"kind"`
+	Router netip.Addr    `json:"router"`
+	Prefix *netip.Prefix `json:"prefix,omitempty"`
+	// Far addresses only in the second and only in the first FIB.
+	Added   []netip.Addr `json:"added,omitempty"`
+	Removed []netip.Addr `json:"removed,omitempty"`
+	Churn   *RouterChurn `json:"churn,omitempty"`
+}
+
+// RouterChurn counts the prefix changes of a router.
+type RouterChurn struct {
+	PrefixesA int `json:"prefixes_a"`
+	PrefixesB int `json:"prefixes_b"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+	// Fraction of the prefixes of either FIB that were added, removed or
+	// changed.
+	Ratio float64 `json:"ratio"`
+}
+
+// Creates the churn of a router only in one of the FIBs.
+func newRouterChurn(prefixesA, prefixesB int) *RouterChurn {
+	return &RouterChurn{
+		PrefixesA: prefixesA,
+		PrefixesB: prefixesB,
+		Added:     prefixesB,
+		Removed:   prefixesA,
+		Ratio:     1,
+	}
+}
+
+// DiffSummary counts the changes between two FIBs.
+type DiffSummary struct {
+	RoutersAdded     int `json:"routers_added"`
+	RoutersRemoved   int `json:"routers_removed"`
+	RoutersChanged   int `json:"routers_changed"`
+	RoutersUnchanged int `json:"routers_unchanged"`
+	PrefixesAdded    int `json:"prefixes_added"`
+	PrefixesRemoved  int `json:"prefixes_removed"`
+	PrefixesChanged  int `json:"prefixes_changed"`
+}
+
+// Compares the FIBs and calls fn with every change from a to b, the routers
+// ordered by their address and the prefixes in order. The prefixes of the
+// added and removed routers are not listed. Returning false from fn stops the
+// comparison.
+func Diff(a, b *FIB, fn func(change *Change) bool) DiffSummary {
+	var summary DiffSummary
+
+	routers := append(a.addrs(false), b.addrs(false)...)
+	slices.SortFunc(routers, netip.Addr.Compare)
+	routers = slices.Compact(routers)
+
+	for _, router := range routers {
+		ftA, inA := a.fibs[router]
+		ftB, inB := b.fibs[router]
+
+		var ok bool
+		switch {
+		case !inB:
+			summary.RoutersRemoved++
+			summary.PrefixesRemoved += ftA.Len()
+			ok = fn(&Change{Kind: RouterRemoved, Router: router, Churn: newRouterChurn(ftA.Len(), 0)})
+		case !inA:
+			summary.RoutersAdded++
+			summary.PrefixesAdded += ftB.Len()
+			ok = fn(&Change{Kind: RouterAdded, Router: router, Churn: newRouterChurn(0, ftB.Len())})
+		default:
+			var churn RouterChurn
+			churn, ok = diffTables(router, ftA, ftB, fn)
+			summary.PrefixesAdded += churn.Added
+			summary.PrefixesRemoved += churn.Removed
+			summary.PrefixesChanged += churn.Changed
+			if churn.Added+churn.Removed+churn.Changed == 0 {
+				summary.RoutersUnchanged++
+			} else {
+				summary.RoutersChanged++
+				ok = ok && fn(&Change{Kind: RouterChanged, Router: router, Churn: &churn})
+			}
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return summary
+}
+
+// Compares the forwarding tables of a router, returns false if fn stopped
+// the comparison.
+func diffTables(router netip.Addr, a, b *FT, fn func(change *Change) bool) (RouterChurn, bool) {
+	churn := RouterChurn{PrefixesA: a.Len(), PrefixesB: b.Len()}
+	ok := true
+
+	a.Walk(func(prefix netip.Prefix, entryA *FTEntry) bool {
+		entryB, found := b.get(prefix)
+		switch {
+		case !found:
+			churn.Removed++
+			ok = fn(&Change{Kind: PrefixRemoved, Router: router, Prefix: &prefix, Removed: entryA.Addrs()})
+		case !entryA.Equal(entryB):
+			churn.Changed++
+			ok = fn(&Change{
+				Kind:    PrefixChanged,
+				Router:  router,
+				Prefix:  &prefix,
+				Added:   entryB.Difference(entryA).Addrs(),
+				Removed: entryA.Difference(entryB).Addrs(),
+			})
+		default:
+			churn.Unchanged++
+		}
+		return ok
+	})
+	if !ok {
+		return churn, false
+	}
+
+	b.Walk(func(prefix netip.Prefix, entryB *FTEntry) bool {
+		if _, found := a.get(prefix); !found {
+			churn.Added++
+			ok = fn(&Change{Kind: PrefixAdded, Router: router, Prefix: &prefix, Added: entryB.Addrs()})
+		}
+		return ok
+	})
+
+	changes := churn.Added + churn.Removed + churn.Changed
+	if total := changes + churn.Unchanged; total > 0 {
+		churn.Ratio = float64(changes) / float64(total)
+	}
+	return churn, ok
+}
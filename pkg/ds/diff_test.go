@@ -0,0 +1,117 @@
+package ds
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// Builds a FIB from near, prefix and far triples.
+func testFIB(triples ...[3]string) *FIB {
+	f := NewFIB(4, true, 24, 48)
+	for _, triple := range triples {
+		f.InsertPrefix(netip.MustParseAddr(triple[0]), netip.MustParsePrefix(triple[1]), netip.MustParseAddr(triple[2]))
+	}
+	return f
+}
+
+// Formats the change on a line for the comparisons.
+func formatChange(c *Change) string {
+	s := fmt.Sprintf("%v %v", c.Kind, c.Router)
+	if c.Prefix != nil {
+		s += " " + c.Prefix.String()
+	}
+	if len(c.Added) > 0 {
+		s += fmt.Sprintf(" +%v", c.Added)
+	}
+	if len(c.Removed) > 0 {
+		s += fmt.Sprintf(" -%v", c.Removed)
+	}
+	if c.Churn != nil {
+		s += fmt.Sprintf(" %+v", *c.Churn)
+	}
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	a := testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.10.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.11.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.4"},
+		[3]string{"10.0.0.5", "8.8.8.0/24", "10.0.0.6"},
+	)
+	b := testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.7"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.10.0/24", "10.0.0.7"},
+		[3]string{"10.0.0.1", "8.8.12.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.4"},
+		[3]string{"2001:db8::1", "2a00::/48", "2001:db8::2"},
+		[3]string{"2001:db8::1", "2a01::/48", "2001:db8::2"},
+	)
+
+	var changes []string
+	summary := Diff(a, b, func(c *Change) bool {
+		changes = append(changes, formatChange(c))
+		return true
+	})
+
+	want := []string{
+		"prefix_changed 10.0.0.1 8.8.9.0/24 +[10.0.0.7]",
+		"prefix_changed 10.0.0.1 8.8.10.0/24 +[10.0.0.7] -[10.0.0.2]",
+		"prefix_removed 10.0.0.1 8.8.11.0/24 -[10.0.0.2]",
+		"prefix_added 10.0.0.1 8.8.12.0/24 +[10.0.0.2]",
+		"router_changed 10.0.0.1 {PrefixesA:4 PrefixesB:4 Added:1 Removed:1 Changed:2 Unchanged:1 Ratio:0.8}",
+		"router_removed 10.0.0.5 {PrefixesA:1 PrefixesB:0 Added:0 Removed:1 Changed:0 Unchanged:0 Ratio:1}",
+		"router_added 2001:db8::1 {PrefixesA:0 PrefixesB:2 Added:2 Removed:0 Changed:0 Unchanged:0 Ratio:1}",
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("changes:\n%q\nwant:\n%q", changes, want)
+	}
+
+	wantSummary := DiffSummary{
+		RoutersAdded:     1,
+		RoutersRemoved:   1,
+		RoutersChanged:   1,
+		RoutersUnchanged: 1,
+		PrefixesAdded:    3,
+		PrefixesRemoved:  2,
+		PrefixesChanged:  2,
+	}
+	if summary != wantSummary {
+		t.Errorf("summary %+v, want %+v", summary, wantSummary)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	a := testFIB([3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"})
+	summary := Diff(a, a, func(c *Change) bool {
+		t.Errorf("unexpected change %v", formatChange(c))
+		return true
+	})
+	if summary != (DiffSummary{RoutersUnchanged: 1}) {
+		t.Errorf("summary %+v", summary)
+	}
+}
+
+func TestDiffStops(t *testing.T) {
+	a := testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.3", "8.8.8.0/24", "10.0.0.2"},
+	)
+	b := testFIB([3]string{"10.0.0.9", "8.8.8.0/24", "10.0.0.2"})
+
+	calls := 0
+	Diff(a, b, func(c *Change) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("fn called %v times after returning false", calls)
+	}
+}
@@ -0,0 +1,20 @@
+// Package dstest provides the FIB fixtures shared by the tests of the packages
+// built on pkg/ds.
+package dstest
+
+import (
+	"net/netip"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// Builds a FIB optimized for IPv4 from near address, prefix and far address
+// triples, with the default /24 and /48 prefix lengths. It panics on invalid
+// triples, they are written in the tests.
+func NewFIB(triples ...[3]string) *ds.FIB {
+	f := ds.NewFIB(uint(len(triples)), true, 24, 48)
+	for _, triple := range triples {
+		f.InsertPrefix(netip.MustParseAddr(triple[0]), netip.MustParsePrefix(triple[1]), netip.MustParseAddr(triple[2]))
+	}
+	return f
+}
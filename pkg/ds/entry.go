@@ -0,0 +1,343 @@
+package ds
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sets with more addresses than this keep an index of their addresses.
+const entryIndexThreshold = 8
+
+// Observation is what the NFP records tell about a next hop of a prefix.
+type Observation struct {
+	// Number of records supporting the next hop.
+	Count uint64
+	// Capture times of the first and the last records, zero if unknown.
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Measurement rounds of the first and the last records, zero if unknown.
+	FirstRound uint32
+	LastRound  uint32
+}
+
+// Creates the observation of a single record, the zero time and round mean
+// they are unknown.
+func NewObservation(timestamp time.Time, round uint32) Observation {
+	return Observation{
+		Count:      1,
+		FirstSeen:  timestamp,
+		LastSeen:   timestamp,
+		FirstRound: round,
+		LastRound:  round,
+	}
+}
+
+// observation is the compact form of Observation kept in the sets, times are
+// unix seconds and zero values are unknown.
+type observation struct {
+	count      uint64
+	firstSeen  int64
+	lastSeen   int64
+	firstRound uint32
+	lastRound  uint32
+}
+
+func newObservation(o Observation) observation {
+	return observation{
+		count:      o.Count,
+		firstSeen:  unixSeconds(o.FirstSeen),
+		lastSeen:   unixSeconds(o.LastSeen),
+		firstRound: o.FirstRound,
+		lastRound:  o.LastRound,
+	}
+}
+
+func (o observation) Observation() Observation {
+	return Observation{
+		Count:      o.count,
+		FirstSeen:  fromUnixSeconds(o.firstSeen),
+		LastSeen:   fromUnixSeconds(o.lastSeen),
+		FirstRound: o.firstRound,
+		LastRound:  o.lastRound,
+	}
+}
+
+// Adds the other observation, the known first values are kept if they are
+// earlier and the known last values if they are later.
+func (o *observation) merge(other observation) {
+	o.count += other.count
+	o.firstSeen = minKnown(o.firstSeen, other.firstSeen)
+	o.lastSeen = max(o.lastSeen, other.lastSeen)
+	o.firstRound = minKnown(o.firstRound, other.firstRound)
+	o.lastRound = max(o.lastRound, other.lastRound)
+}
+
+// Returns the smallest value, zero values are unknown and ignored.
+func minKnown[T int64 | uint32](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnixSeconds(s int64) time.Time {
+	if s == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s, 0).UTC()
+}
+
+// FTEntry is an object that denotes the next hops of a router. It is a set because
+// there can be ECPM and load balancing enabled.
+//
+// The addresses are kept sorted with the observation of each one. Small sets
+// are searched directly, which is faster than hashing for the one or two next
+// hops most prefixes have. Past entryIndexThreshold addresses the
+// observations move into a map for constant time membership.
+type FTEntry struct {
+	// Sorted addresses of the set.
+	addrs []netip.Addr
+	// Observations of the addresses of the same index, only used while the
+	// set is small.
+	observations []observation
+	// Observations by address, only used once the set is large.
+	index map[netip.Addr]observation
+}
+
+// Creates a new NHSet struct.
+func newFTEntry(size uint) *FTEntry {
+	return &FTEntry{
+		addrs:        make([]netip.Addr, 0, size),
+		observations: make([]observation, 0, size),
+	}
+}
+
+// Creates a new set of the given addresses, each observed once.
+func NewFTEntry(addrs ...netip.Addr) *FTEntry {
+	n := newFTEntry(uint(len(addrs)))
+	for _, addr := range addrs {
+		n.AddAddr(addr)
+	}
+	return n
+}
+
+// Adds the ip address if it is not already in the set.
+func (n *FTEntry) Add(ip *net.IP) {
+	if addr, err := AddrFromIP(ip); err == nil {
+		n.AddAddr(addr)
+	}
+}
+
+// Adds the address if it is not already in the set and counts the
+// observation.
+func (n *FTEntry) AddAddr(addr netip.Addr) {
+	n.AddCount(addr, 1)
+}
+
+// Adds the address if it is not already in the set and counts it as observed
+// count more times.
+func (n *FTEntry) AddCount(addr netip.Addr, count uint64) {
+	n.add(addr, observation{count: count})
+}
+
+// Adds the address if it is not already in the set and merges the
+// observation into the one of the address.
+func (n *FTEntry) Observe(addr netip.Addr, o Observation) {
+	n.add(addr, newObservation(o))
+}
+
+func (n *FTEntry) add(addr netip.Addr, o observation) {
+	if n.index != nil {
+		existing, found := n.index[addr]
+		if !found {
+			i, _ := slices.BinarySearchFunc(n.addrs, addr, netip.Addr.Compare)
+			n.addrs = slices.Insert(n.addrs, i, addr)
+			existing = o
+		} else {
+			existing.merge(o)
+		}
+		n.index[addr] = existing
+		return
+	}
+
+	i, found := n.search(addr)
+	if found {
+		n.observations[i].merge(o)
+		return
+	}
+	n.addrs = slices.Insert(n.addrs, i, addr)
+	n.observations = slices.Insert(n.observations, i, o)
+
+	if len(n.addrs) > entryIndexThreshold {
+		n.index = make(map[netip.Addr]observation, 2*len(n.addrs))
+		for i, addr := range n.addrs {
+			n.index[addr] = n.observations[i]
+		}
+		n.observations = nil
+	}
+}
+
+// Returns the position of the address in the small set, or where it would be.
+func (n *FTEntry) search(addr netip.Addr) (int, bool) {
+	for i, existing := range n.addrs {
+		if c := existing.Compare(addr); c >= 0 {
+			return i, c == 0
+		}
+	}
+	return len(n.addrs), false
+}
+
+// Checks if the given IP address is already in the set.
+func (n *FTEntry) Contains(ip *net.IP) bool {
+	addr, err := AddrFromIP(ip)
+	if err != nil {
+		return false
+	}
+	return n.ContainsAddr(addr)
+}
+
+// Checks if the given address is already in the set.
+func (n *FTEntry) ContainsAddr(addr netip.Addr) bool {
+	if n.index != nil {
+		_, found := n.index[addr]
+		return found
+	}
+	_, found := n.search(addr)
+	return found
+}
+
+// Returns the number of times the address was observed, 0 if it is not in
+// the set.
+func (n *FTEntry) Count(addr netip.Addr) uint64 {
+	o, _ := n.Observation(addr)
+	return o.Count
+}
+
+// Returns the observation of the address, false if it is not in the set.
+func (n *FTEntry) Observation(addr netip.Addr) (Observation, bool) {
+	if n.index != nil {
+		o, found := n.index[addr]
+		return o.Observation(), found
+	}
+	if i, found := n.search(addr); found {
+		return n.observations[i].Observation(), true
+	}
+	return Observation{}, false
+}
+
+// Returns the total number of observations of all the addresses.
+func (n *FTEntry) Total() uint64 {
+	var total uint64
+	n.walk(func(addr netip.Addr, o observation) bool {
+		total += o.count
+		return true
+	})
+	return total
+}
+
+// Walks the addresses in order with their observations. Returning false from
+// fn stops the walk.
+func (n *FTEntry) Walk(fn func(addr netip.Addr, o Observation) bool) {
+	n.walk(func(addr netip.Addr, o observation) bool {
+		return fn(addr, o.Observation())
+	})
+}
+
+func (n *FTEntry) walk(fn func(addr netip.Addr, o observation) bool) {
+	for i, addr := range n.addrs {
+		var o observation
+		if n.index != nil {
+			o = n.index[addr]
+		} else {
+			o = n.observations[i]
+		}
+		if !fn(addr, o) {
+			return
+		}
+	}
+}
+
+// Checks if both sets have the same addresses, the counts are not compared.
+func (n *FTEntry) Equal(o *FTEntry) bool {
+	return slices.Equal(n.addrs, o.addrs)
+}
+
+// Returns a new set with the addresses of both sets, the observations of the
+// common addresses are merged.
+func (n *FTEntry) Union(o *FTEntry) *FTEntry {
+	u := n.Clone()
+	u.Merge(o)
+	return u
+}
+
+// Adds the addresses of o into the set, the observations of the common
+// addresses are merged.
+func (n *FTEntry) Merge(o *FTEntry) {
+	o.walk(func(addr netip.Addr, obs observation) bool {
+		n.add(addr, obs)
+		return true
+	})
+}
+
+// Returns a new set with the addresses in both sets, with the observations of
+// n.
+func (n *FTEntry) Intersect(o *FTEntry) *FTEntry {
+	return n.filter(func(addr netip.Addr) bool { return o.ContainsAddr(addr) })
+}
+
+// Returns a new set with the addresses of n that are not in o, with the
+// observations of n.
+func (n *FTEntry) Difference(o *FTEntry) *FTEntry {
+	return n.filter(func(addr netip.Addr) bool { return !o.ContainsAddr(addr) })
+}
+
+// Returns a copy of the set.
+func (n *FTEntry) Clone() *FTEntry {
+	return n.filter(func(addr netip.Addr) bool { return true })
+}
+
+// Returns a new set with the addresses of n that are kept by fn.
+func (n *FTEntry) filter(keep func(addr netip.Addr) bool) *FTEntry {
+	f := newFTEntry(DefaultEntrySize)
+	n.walk(func(addr netip.Addr, o observation) bool {
+		if keep(addr) {
+			f.add(addr, o)
+		}
+		return true
+	})
+	return f
+}
+
+// Returns the addresses in the set in order, the slice must not be modified.
+func (n *FTEntry) Addrs() []netip.Addr {
+	return n.addrs
+}
+
+// Returns the number of addresses in the set.
+func (n *FTEntry) Len() int {
+	return len(n.addrs)
+}
+
+// ToString method returns a string representation of all IPs
+func (ft *FTEntry) String() string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, ip := range ft.addrs {
+		sb.WriteString(ip.String())
+		if i < len(ft.addrs)-1 {
+			sb.WriteString(", ")
+		}
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
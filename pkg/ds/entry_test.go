@@ -0,0 +1,192 @@
+package ds
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Returns the addresses 10.0.0.i for every i.
+func testAddrs(indexes ...int) []netip.Addr {
+	addrs := make([]netip.Addr, len(indexes))
+	for i, index := range indexes {
+		addrs[i] = netip.AddrFrom4([4]byte{10, 0, 0, byte(index)})
+	}
+	return addrs
+}
+
+// Returns the indexes from..to-1.
+func span(from, to int) []int {
+	indexes := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// Builds a set with every address observed count times, inserted in reverse
+// so the set has to keep them sorted.
+func testEntry(count uint64, indexes ...int) *FTEntry {
+	entry := NewFTEntry()
+	addrs := testAddrs(indexes...)
+	for i := len(addrs) - 1; i >= 0; i-- {
+		entry.AddCount(addrs[i], count)
+	}
+	return entry
+}
+
+func TestFTEntrySetOperations(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b         []int
+		union        []int
+		intersection []int
+		difference   []int
+	}{
+		{"empty", nil, nil, nil, nil, nil},
+		{"small disjoint", []int{1, 2}, []int{3}, []int{1, 2, 3}, nil, []int{1, 2}},
+		{"small overlap", []int{1, 2, 3}, []int{2, 3, 4}, []int{1, 2, 3, 4}, []int{2, 3}, []int{1}},
+		{"at the threshold", span(0, 8), span(4, 8), span(0, 8), span(4, 8), span(0, 4)},
+		{"union crosses the threshold", span(0, 5), span(3, 9), span(0, 9), span(3, 5), span(0, 3)},
+		{"indexed and small", span(0, 20), []int{5, 25}, append(span(0, 20), 25), []int{5}, append(span(0, 5), span(6, 20)...)},
+		{"both indexed", span(0, 20), span(10, 30), span(0, 30), span(10, 20), span(0, 10)},
+		{"intersection below the threshold", span(0, 12), span(10, 22), span(0, 22), span(10, 12), span(0, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := testEntry(1, tt.a...), testEntry(2, tt.b...)
+
+			check := func(operation string, got *FTEntry, want []int, count func(addr netip.Addr) uint64) {
+				t.Helper()
+				if !slices.Equal(got.Addrs(), testAddrs(want...)) {
+					t.Errorf("%v = %v, want %v", operation, got, testAddrs(want...))
+				}
+				if indexed := got.index != nil; indexed != (got.Len() > entryIndexThreshold) {
+					t.Errorf("%v of %v addresses has an index: %v", operation, got.Len(), indexed)
+				}
+				for _, addr := range got.Addrs() {
+					if !got.ContainsAddr(addr) || got.Count(addr) != count(addr) {
+						t.Errorf("%v: count of %v = %v, want %v", operation, addr, got.Count(addr), count(addr))
+					}
+				}
+			}
+
+			check("union", a.Union(b), tt.union, func(addr netip.Addr) uint64 { return a.Count(addr) + b.Count(addr) })
+			check("intersection", a.Intersect(b), tt.intersection, a.Count)
+			check("difference", a.Difference(b), tt.difference, a.Count)
+			check("clone", a.Clone(), tt.a, a.Count)
+
+			if !a.Union(b).Equal(b.Union(a)) {
+				t.Error("the union is not symmetric")
+			}
+			if a.Total() != uint64(len(tt.a)) || b.Total() != 2*uint64(len(tt.b)) {
+				t.Errorf("totals %v and %v", a.Total(), b.Total())
+			}
+
+			merged := a.Clone()
+			merged.Merge(b)
+			if !merged.Equal(a.Union(b)) || a.Len() != len(tt.a) {
+				t.Error("Merge differs from Union or modified its input")
+			}
+		})
+	}
+}
+
+func TestFTEntryMissingAddress(t *testing.T) {
+	for _, entry := range []*FTEntry{testEntry(1, 1, 2), testEntry(1, span(0, 20)...)} {
+		missing := netip.MustParseAddr("192.0.2.1")
+		if entry.ContainsAddr(missing) || entry.Count(missing) != 0 {
+			t.Errorf("%v contains %v", entry, missing)
+		}
+		if _, found := entry.Observation(missing); found {
+			t.Errorf("%v has an observation of %v", entry, missing)
+		}
+	}
+}
+
+func TestFTEntryWalkStops(t *testing.T) {
+	for _, entry := range []*FTEntry{testEntry(1, span(0, 5)...), testEntry(1, span(0, 20)...)} {
+		var walked []netip.Addr
+		entry.Walk(func(addr netip.Addr, o Observation) bool {
+			walked = append(walked, addr)
+			return len(walked) < 3
+		})
+		if !slices.Equal(walked, testAddrs(0, 1, 2)) {
+			t.Errorf("walked %v", walked)
+		}
+	}
+}
+
+func TestObservationMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b, want observation
+	}{
+		{
+			name: "both known",
+			a:    observation{count: 1, firstSeen: 100, lastSeen: 200, firstRound: 2, lastRound: 3},
+			b:    observation{count: 2, firstSeen: 50, lastSeen: 150, firstRound: 1, lastRound: 4},
+			want: observation{count: 3, firstSeen: 50, lastSeen: 200, firstRound: 1, lastRound: 4},
+		},
+		{
+			name: "unknown into known",
+			a:    observation{count: 1, firstSeen: 100, lastSeen: 200, firstRound: 2, lastRound: 3},
+			b:    observation{count: 1},
+			want: observation{count: 2, firstSeen: 100, lastSeen: 200, firstRound: 2, lastRound: 3},
+		},
+		{
+			name: "known into unknown",
+			a:    observation{count: 1},
+			b:    observation{count: 4, firstSeen: 100, lastSeen: 200, firstRound: 2, lastRound: 3},
+			want: observation{count: 5, firstSeen: 100, lastSeen: 200, firstRound: 2, lastRound: 3},
+		},
+		{
+			name: "both unknown",
+			a:    observation{count: 1},
+			b:    observation{count: 1},
+			want: observation{count: 2},
+		},
+		{
+			name: "times known and rounds unknown",
+			a:    observation{count: 1, firstSeen: 300, lastSeen: 300},
+			b:    observation{count: 1, firstRound: 7, lastRound: 7},
+			want: observation{count: 2, firstSeen: 300, lastSeen: 300, firstRound: 7, lastRound: 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.a
+			got.merge(tt.b)
+			if got != tt.want {
+				t.Errorf("merge = %+v, want %+v", got, tt.want)
+			}
+			// The merge is symmetric.
+			got = tt.b
+			got.merge(tt.a)
+			if got != tt.want {
+				t.Errorf("reversed merge = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObservationConversion(t *testing.T) {
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := NewFTEntry()
+	addr := netip.MustParseAddr("10.0.0.1")
+	entry.Observe(addr, NewObservation(seen, 5))
+	entry.Observe(addr, NewObservation(time.Time{}, 0))
+	entry.Observe(addr, NewObservation(seen.Add(time.Hour), 6))
+
+	got, found := entry.Observation(addr)
+	want := Observation{Count: 3, FirstSeen: seen, LastSeen: seen.Add(time.Hour), FirstRound: 5, LastRound: 6}
+	if !found || got != want {
+		t.Errorf("Observation = %+v, want %+v", got, want)
+	}
+
+	unknown := NewFTEntry(addr)
+	if got, _ := unknown.Observation(addr); got != (Observation{Count: 1}) {
+		t.Errorf("Observation of AddAddr = %+v, want a count of 1 only", got)
+	}
+}
@@ -0,0 +1,133 @@
+package ds
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+)
+
+// ExportOptions configures how a FIB is exported.
+type ExportOptions struct {
+	Format ExportFormat
+	// Sorted exports the routers ordered by their address. The prefixes of a
+	// router are always ordered.
+	Sorted bool
+	// AS annotates the rows with the origin ASes if set.
+	AS ASAnnotator
+}
+
+// ASAnnotator maps an address to the AS originating it.
+type ASAnnotator interface {
+	OriginAS(address netip.Addr) (uint32, bool)
+}
+
+// Returns the origin AS of the address, nil if it is unknown so it is written
+// empty.
+func originAS(annotator ASAnnotator, address netip.Addr) any {
+	if origin, found := annotator.OriginAS(address); found {
+		return origin
+	}
+	return nil
+}
+
+// Returns true if both ASes are known and differ, nil if one is unknown.
+func interAS(nearAS, farAS any) any {
+	if nearAS == nil || farAS == nil {
+		return nil
+	}
+	return nearAS != farAS
+}
+
+// Returns the router addresses, ordered if asked.
+func (f *FIB) addrs(sorted bool) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(f.fibs))
+	for address := range f.fibs {
+		addrs = append(addrs, address)
+	}
+	if sorted {
+		slices.SortFunc(addrs, netip.Addr.Compare)
+	}
+	return addrs
+}
+
+// Walks the routers of the FIB, ordered by their address if sorted is set.
+// Returning false from fn stops the walk.
+func (f *FIB) WalkOrdered(sorted bool, fn func(address netip.Addr, ft *FT) bool) {
+	if !sorted {
+		f.Walk(fn)
+		return
+	}
+	for _, address := range f.addrs(true) {
+		if !fn(address, f.fibs[address]) {
+			return
+		}
+	}
+}
+
+// Writes one row per near address, prefix and far address triple into the
+// writer while walking the FIB, the rows are never built in memory. Every row
+// has the number of records supporting it and, when known, the capture times
+// and rounds of the first and the last ones. With an AS annotator, the rows
+// also have the origin ASes of the near address, the prefix and the far
+// address, and whether the edge crosses ASes. The routers collapsed from an
+// alias file are written with their key, see RouterID.
+func (f *FIB) Export(w io.Writer, opts ExportOptions) error {
+	columns := []string{"near_addr", "prefix", "far_addr",
+		"count", "first_seen", "last_seen", "first_round", "last_round"}
+	if opts.AS != nil {
+		columns = append(columns, "near_as", "prefix_as", "far_as", "inter_as")
+	}
+	t, err := NewTableWriter(w, opts.Format, columns...)
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, 0, len(columns))
+	f.WalkOrdered(opts.Sorted, func(nearAddress netip.Addr, ft *FT) bool {
+		var nearAS any
+		if opts.AS != nil {
+			nearAS = originAS(opts.AS, nearAddress)
+		}
+		ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+			var prefixAS any
+			if opts.AS != nil {
+				prefixAS = originAS(opts.AS, prefix.Addr())
+			}
+			entry.Walk(func(farAddress netip.Addr, o Observation) bool {
+				values = append(values[:0], f.nearKey(nearAddress), prefix, f.farKey(farAddress),
+					o.Count, o.FirstSeen, o.LastSeen, optionalRound(o.FirstRound), optionalRound(o.LastRound))
+				if opts.AS != nil {
+					farAS := originAS(opts.AS, farAddress)
+					values = append(values, nearAS, prefixAS, farAS, interAS(nearAS, farAS))
+				}
+				err = t.Write(values...)
+				return err == nil
+			})
+			return err == nil
+		})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return t.Flush()
+}
+
+// Returns nil for the unknown rounds so they are written empty.
+func optionalRound(round uint32) any {
+	if round == 0 {
+		return nil
+	}
+	return round
+}
+
+// Writes the FIB in the format of String, one router at a time.
+func (f *FIB) WriteText(w io.Writer, sorted bool) error {
+	var err error
+	f.WalkOrdered(sorted, func(nearAddress netip.Addr, ft *FT) bool {
+		_, err = fmt.Fprintf(w, "%v:\n%v", f.nearKey(nearAddress), ft)
+		return err == nil
+	})
+	return err
+}
@@ -2,128 +2,201 @@ package ds
 
 import (
 	"fmt"
-	"log"
 	"net"
+	"net/netip"
 	"strings"
 )
 
 // FI stands for forwarding information base
 type FIB struct {
-	fibs                map[string]*FT
-	optimizeForIPv4     bool
-	defaultPrefixLength uint
+	fibs             map[netip.Addr]*FT
+	optimizeForIPv4  bool
+	ipv4PrefixLength uint
+	ipv6PrefixLength uint
+	// Node identifiers of the routers collapsed from an alias file, by the
+	// address keying their table. The far addresses are routers too if
+	// farRouters is set.
+	routerIDs  map[netip.Addr]string
+	farRouters bool
 }
 
-// Creates a new forwarding information base.
-func NewFIB(size uint, optimizeForIPv4 bool, defaultPrefixLength uint) *FIB {
+// Creates a new forwarding information base. The prefix lengths are used for
+// the destination addresses of IPv4 and IPv6 respectively.
+func NewFIB(size uint, optimizeForIPv4 bool, ipv4PrefixLength uint, ipv6PrefixLength uint) *FIB {
 	return &FIB{
-		fibs:                make(map[string]*FT, size),
-		optimizeForIPv4:     optimizeForIPv4,
-		defaultPrefixLength: defaultPrefixLength,
+		fibs:             make(map[netip.Addr]*FT, size),
+		optimizeForIPv4:  optimizeForIPv4,
+		ipv4PrefixLength: ipv4PrefixLength,
+		ipv6PrefixLength: ipv6PrefixLength,
 	}
 }
 
 // Gets the FI of the router address.
 func (f *FIB) Get(address *net.IP) (*FT, bool, error) {
-	key, err := IPToKey(address)
+	addr, err := AddrFromIP(address)
 	if err != nil {
 		return nil, false, err
 	}
-	if ft, ok := f.fibs[key]; !ok || ft == nil {
-		return nil, false, nil
+	ft, found := f.GetAddr(addr)
+	return ft, found, nil
+}
+
+// Gets the FI of the router address.
+func (f *FIB) GetAddr(address netip.Addr) (*FT, bool) {
+	if ft, ok := f.fibs[address.Unmap()]; !ok || ft == nil {
+		return nil, false
 	} else {
-		return ft, true, nil
+		return ft, true
+	}
+}
+
+// Performs a longest prefix match for the destination on the forwarding table
+// of the near router. It returns the matched prefix and its next hops.
+func (f *FIB) Lookup(near *net.IP, dst *net.IP) (netip.Prefix, *FTEntry, bool, error) {
+	nearAddr, err := AddrFromIP(near)
+	if err != nil {
+		return netip.Prefix{}, nil, false, err
 	}
+	dstAddr, err := AddrFromIP(dst)
+	if err != nil {
+		return netip.Prefix{}, nil, false, err
+	}
+	prefix, entry, found := f.LookupAddr(nearAddr, dstAddr)
+	return prefix, entry, found, nil
+}
+
+// Performs a longest prefix match for the destination on the forwarding table
+// of the near router. It returns the matched prefix and its next hops.
+func (f *FIB) LookupAddr(near netip.Addr, dst netip.Addr) (netip.Prefix, *FTEntry, bool) {
+	ft, found := f.GetAddr(near)
+	if !found {
+		return netip.Prefix{}, nil, false
+	}
+	return ft.LookupAddr(dst.Unmap())
 }
 
 // Inserts a new forwarding info as defined in the forwarding info design document.
 func (f *FIB) Insert(address *net.IP, network *net.IPNet, nexthop *net.IP) error {
-	key, err := IPToKey(address)
+	if network == nil || nexthop == nil {
+		return ErrGivenAddressNil
+	}
+	addr, err := AddrFromIP(address)
 	if err != nil {
 		return err
 	}
-	if ft, ok := f.fibs[key]; !ok || ft == nil {
-		entry := NewFowardingTable(f.optimizeForIPv4, f.defaultPrefixLength)
-		err := entry.Insert(network, nexthop)
-		if err != nil {
-			return err
-		}
-
-		f.fibs[key] = entry
-		return nil
-	} else {
-		return ft.Insert(network, nexthop)
+	prefix, err := PrefixFromNetwork(network)
+	if err != nil {
+		return err
+	}
+	nexthopAddr, err := AddrFromIP(nexthop)
+	if err != nil {
+		return err
 	}
+
+	f.InsertPrefix(addr, prefix, nexthopAddr)
+	return nil
 }
 
-// Converts the forwarding table into a String
-func (f *FIB) String() string {
-	var sb strings.Builder
+// Inserts a new forwarding info for the router address.
+func (f *FIB) InsertPrefix(address netip.Addr, prefix netip.Prefix, nexthop netip.Addr) {
+	f.table(address).InsertPrefix(prefix, nexthop.Unmap())
+}
 
-	for k, v := range f.fibs {
-		nearAddress, err := KeyToIP(k)
-		if err != nil {
-			panic(err)
-		}
-		sb.WriteString(fmt.Sprintf("%v:\n%v", nearAddress, v))
+// Inserts a new forwarding info for the router address with what is known
+// about the record supporting it.
+func (f *FIB) InsertObservation(address netip.Addr, prefix netip.Prefix, nexthop netip.Addr, o Observation) {
+	f.table(address).InsertObservation(prefix, nexthop.Unmap(), o)
+}
+
+// Inserts a new forwarding info for the network of the destination address,
+// using the default prefix length of its family.
+func (f *FIB) InsertAddr(address netip.Addr, dst netip.Addr, nexthop netip.Addr) error {
+	prefix, err := DefaultPrefix(dst, f.ipv4PrefixLength, f.ipv6PrefixLength)
+	if err != nil {
+		return err
 	}
+	f.InsertPrefix(address, prefix, nexthop)
+	return nil
+}
 
-	return sb.String()
+// Returns the forwarding table of the router address, creating it if needed.
+func (f *FIB) table(address netip.Addr) *FT {
+	address = address.Unmap()
+	ft, ok := f.fibs[address]
+	if !ok || ft == nil {
+		ft = NewFowardingTable(f.optimizeForIPv4, f.ipv4PrefixLength, f.ipv6PrefixLength)
+		f.fibs[address] = ft
+	}
+	return ft
 }
 
-// This function computes the number of hosts and number of entries for a
-// given address.
-func (f *FIB) ToIPInfo(postfixLength int) string {
-	var sb strings.Builder
+// Adds the routers and prefixes of the other FIB into this one. The next hop
+// sets of the common prefixes are joined and their observations merged, so
+// merging the FIBs built from two sets of records gives the FIB built from
+// both. The router identifiers of the other FIB are kept too. The other FIB is
+// not modified.
+func (f *FIB) Merge(other *FIB) {
+	for address, ft := range other.fibs {
+		f.table(address).Merge(ft)
+	}
+	for address, id := range other.routerIDs {
+		f.setRouterID(address, id)
+	}
+	f.farRouters = f.farRouters || other.farRouters
+}
+
+// Returns the IPv4 and IPv6 prefix lengths used when inserting a destination
+// address.
+func (f *FIB) DefaultPrefixLengths() (uint, uint) {
+	return f.ipv4PrefixLength, f.ipv6PrefixLength
+}
 
-	sb.WriteString("\"address\",\"num_networks\",\"num_hosts\"\n")
+// Returns the number of routers in the FIB.
+func (f *FIB) Len() int {
+	return len(f.fibs)
+}
 
-	for k, v := range f.fibs {
-		nearAddress, err := KeyToIP(k)
-		if err != nil {
-			panic(err)
+// Walks the routers of the FIB in no particular order. Returning false from fn
+// stops the walk.
+func (f *FIB) Walk(fn func(address netip.Addr, ft *FT) bool) {
+	for address, ft := range f.fibs {
+		if !fn(address, ft) {
+			return
 		}
-		num_prefix := v.tree.Len()
-		num_hosts := 1 << postfixLength
-		sb.WriteString(fmt.Sprintf("\"%v\",\"%v\",\"%v\"\n", nearAddress, num_prefix, num_hosts))
 	}
-
-	return sb.String()
 }
 
-// To CSV
-func (f *FIB) ToCSV() string {
-	var sb strings.Builder
+// Summary holds the sizes of a FIB.
+type Summary struct {
+	Routers     int `json:"routers"`
+	Prefixes    int `json:"prefixes"`
+	Edges       int `json:"edges"`
+	MaxPrefixes int `json:"max_prefixes"`
+	MaxNextHops int `json:"max_next_hops"`
+}
 
-	for nearAddressKey, ftObj := range f.fibs {
-		for networkKey, entry := range ftObj.tree.ToMap() {
-			if farAddresses, ok := entry.(*FTEntry); !ok {
-			} else {
-				for _, farAddress := range farAddresses.dset {
-					networkPrefix, err := KeyToIP(networkKey)
-					if err != nil {
-						log.Printf("An error occured while printing: %v.\n", err)
-						continue
-					}
-					network, err := IPToNetwork(networkPrefix, int(f.defaultPrefixLength))
-					if err != nil {
-						log.Printf("An error occured while printing: %v.\n", err)
-						continue
-					}
-
-					nearAddress, err := KeyToIP(nearAddressKey)
-					if err != nil {
-						log.Printf("An error occured while printing: %v.\n", err)
-						continue
-					}
-					sb.WriteString(fmt.Sprintf("\"%v\",\"%v\",\"%v\"\n", nearAddress, network.String(), farAddress))
-				}
-			}
-		}
-		ftObj.tree.WalkPrefix("", func(networkKey string, ftEntry interface{}) bool {
+// Computes the number of routers, prefixes and near-far edges of the FIB.
+func (f *FIB) Summary() Summary {
+	s := Summary{Routers: len(f.fibs)}
+	for _, ft := range f.fibs {
+		s.Prefixes += ft.Len()
+		s.MaxPrefixes = max(s.MaxPrefixes, ft.Len())
+		ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+			s.Edges += entry.Len()
+			s.MaxNextHops = max(s.MaxNextHops, entry.Len())
 			return true
 		})
 	}
+	return s
+}
+
+// Converts the forwarding table into a String
+func (f *FIB) String() string {
+	var sb strings.Builder
+
+	for nearAddress, v := range f.fibs {
+		sb.WriteString(fmt.Sprintf("%v:\n%v", f.nearKey(nearAddress), v))
+	}
 
 	return sb.String()
 }
@@ -0,0 +1,152 @@
+package ds
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// testRecord is a record with its observation.
+type testRecord struct {
+	near, dst, far netip.Addr
+	observation    Observation
+}
+
+// Generates records over a few routers so the FIBs overlap, some of them
+// without a capture time or a round.
+func testRecords(r *rand.Rand, n int) []testRecord {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := make([]testRecord, n)
+	for i := range records {
+		timestamp := start.Add(time.Duration(r.Intn(1000)) * time.Minute)
+		if r.Intn(5) == 0 {
+			timestamp = time.Time{}
+		}
+		records[i] = testRecord{
+			near:        netip.AddrFrom4([4]byte{10, 0, 0, byte(r.Intn(6))}),
+			dst:         randomAddr(r, 0.3),
+			far:         netip.AddrFrom4([4]byte{10, 1, 0, byte(r.Intn(4))}),
+			observation: NewObservation(timestamp, uint32(r.Intn(4))),
+		}
+	}
+	return records
+}
+
+// Builds a FIB from the records.
+func buildTestFIB(t *testing.T, optimizeForIPv4 bool, records ...[]testRecord) *FIB {
+	t.Helper()
+	f := NewFIB(8, optimizeForIPv4, 16, 32)
+	for _, rs := range records {
+		for _, record := range rs {
+			prefix, err := DefaultPrefix(record.dst, 16, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.InsertObservation(record.near, prefix, record.far, record.observation)
+		}
+	}
+	return f
+}
+
+func TestMergeEqualsBuildOfUnion(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, optimizeForIPv4 := range []bool{false, true} {
+		for i := 0; i < 20; i++ {
+			a, b := testRecords(r, 1+r.Intn(500)), testRecords(r, r.Intn(500))
+
+			merged := buildTestFIB(t, optimizeForIPv4, a)
+			other := buildTestFIB(t, optimizeForIPv4, b)
+			otherBefore := exportString(t, other)
+			merged.Merge(other)
+
+			if got, want := exportString(t, merged), exportString(t, buildTestFIB(t, optimizeForIPv4, a, b)); got != want {
+				t.Fatalf("optimize=%v: merge(build(A), build(B)) =\n%v\nbuild(A∪B) =\n%v", optimizeForIPv4, got, want)
+			}
+			if exportString(t, other) != otherBefore {
+				t.Fatalf("optimize=%v: Merge modified the other FIB", optimizeForIPv4)
+			}
+		}
+	}
+}
+
+func TestMergeObservations(t *testing.T) {
+	near := netip.MustParseAddr("10.0.0.1")
+	far := netip.MustParseAddr("10.0.0.2")
+	prefix := netip.MustParsePrefix("8.8.8.0/24")
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := first.Add(time.Hour)
+
+	a := NewFIB(1, true, 24, 48)
+	a.InsertObservation(near, prefix, far, NewObservation(last, 3))
+	a.InsertObservation(near, prefix, far, NewObservation(time.Time{}, 0))
+	b := NewFIB(1, true, 24, 48)
+	b.InsertObservation(near, prefix, far, NewObservation(first, 2))
+	b.InsertObservation(netip.MustParseAddr("10.0.0.9"), prefix, far, NewObservation(first, 1))
+
+	a.Merge(b)
+	_, entry, found := a.LookupAddr(near, netip.MustParseAddr("8.8.8.8"))
+	if !found {
+		t.Fatal("merged prefix not found")
+	}
+	got, _ := entry.Observation(far)
+	want := Observation{Count: 3, FirstSeen: first, LastSeen: last, FirstRound: 2, LastRound: 3}
+	if got != want {
+		t.Errorf("merged observation %+v, want %+v", got, want)
+	}
+	if a.Len() != 2 {
+		t.Errorf("merged FIB has %v routers, want 2", a.Len())
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f := NewFIB(2, true, 24, 48)
+	near := netip.MustParseAddr("10.0.0.1")
+	f.InsertPrefix(near, netip.MustParsePrefix("8.0.0.0/8"), netip.MustParseAddr("10.0.0.2"))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.0.0/16"), netip.MustParseAddr("10.0.0.3"))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.4"))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.5"))
+	f.InsertPrefix(near, netip.MustParsePrefix("2001:db8::/32"), netip.MustParseAddr("10.0.0.6"))
+	f.InsertPrefix(netip.MustParseAddr("10.0.0.8"), netip.MustParsePrefix("::/0"), netip.MustParseAddr("10.0.0.7"))
+	f.InsertPrefix(netip.MustParseAddr("2001:db8::1"), netip.MustParsePrefix("2a00::/16"), netip.MustParseAddr("2001:db8::2"))
+
+	tests := []struct {
+		name     string
+		near     string
+		dst      string
+		prefix   string
+		nextHops string
+	}{
+		{"longest of three", "10.0.0.1", "8.8.8.8", "8.8.8.0/24", "{10.0.0.4, 10.0.0.5}"},
+		{"middle", "10.0.0.1", "8.8.9.1", "8.8.0.0/16", "{10.0.0.3}"},
+		{"shortest", "10.0.0.1", "8.9.0.1", "8.0.0.0/8", "{10.0.0.2}"},
+		{"mapped destination", "10.0.0.1", "::ffff:8.8.8.8", "8.8.8.0/24", "{10.0.0.4, 10.0.0.5}"},
+		{"mapped near", "::ffff:10.0.0.1", "8.8.8.8", "8.8.8.0/24", "{10.0.0.4, 10.0.0.5}"},
+		{"ipv6 destination", "10.0.0.1", "2001:db8::1", "2001:db8::/32", "{10.0.0.6}"},
+		{"no ipv6 route", "10.0.0.1", "2001:db9::1", "", ""},
+		{"no ipv4 route", "10.0.0.1", "9.9.9.9", "", ""},
+		{"ipv6 default route", "10.0.0.8", "2001:db9::1", "::/0", "{10.0.0.7}"},
+		// The IPv6 prefixes covering ::ffff:0:0/96 cover the IPv4 addresses.
+		{"ipv4 on the ipv6 default route", "10.0.0.8", "9.9.9.9", "::/0", "{10.0.0.7}"},
+		{"ipv6 router", "2001:db8::1", "2a00::1", "2a00::/16", "{2001:db8::2}"},
+		{"ipv4 destination on an ipv6 only router", "2001:db8::1", "42.0.0.1", "", ""},
+		{"unknown router", "10.0.0.9", "8.8.8.8", "", ""},
+	}
+	for _, tt := range tests {
+		prefix, entry, found := f.LookupAddr(netip.MustParseAddr(tt.near), netip.MustParseAddr(tt.dst))
+		if found != (tt.prefix != "") {
+			t.Errorf("%v: found = %v", tt.name, found)
+			continue
+		}
+		if found && (prefix.String() != tt.prefix || entry.String() != tt.nextHops) {
+			t.Errorf("%v: got %v %v, want %v %v", tt.name, prefix, entry, tt.prefix, tt.nextHops)
+		}
+
+		near, dst := net.ParseIP(tt.near), net.ParseIP(tt.dst)
+		netPrefix, _, netFound, err := f.Lookup(&near, &dst)
+		if err != nil || netFound != found || netPrefix != prefix {
+			t.Errorf("%v: Lookup = %v, %v, %v, want the same as LookupAddr", tt.name, netPrefix, netFound, err)
+		}
+	}
+}
@@ -1,74 +1,84 @@
 package ds
 
 import (
-	"errors"
 	"fmt"
-	"log"
 	"net"
+	"net/netip"
 	"strings"
-
-	"github.com/armon/go-radix"
 )
 
 const DefaultEntrySize = 1
 
 // This is the main implementation of the forwarding info as stated in the design document.
-// This struct uses a redix tree to perform lookups.
+// This struct uses a binary trie keyed by 128 bit addresses to perform lookups.
+//
+// With this we only have one trie containing everyting. Every prefix keeps its
+// own length, so prefixes of different lengths (/24, /20, /48 or aggregated
+// ones) coexist in the same table. The default prefix lengths of IPv4 and IPv6
+// are only used when inserting a destination address instead of a prefix.
 //
-// With this we only have one radix tree containing everyting.
+// When optimized for IPv4, the IPv4 prefixes are kept in a separate trie with
+// 32 bit keys and only the IPv6 prefixes go to the 128 bit one. Lookups, walks
+// and exports give the same results in both cases.
 type FT struct {
-	tree                *radix.Tree
-	optimizeForIPv4     bool
-	defaultPrefixLength uint
+	tree             *Trie[*FTEntry]
+	tree4            *trie4[*FTEntry]
+	optimizeForIPv4  bool
+	ipv4PrefixLength uint
+	ipv6PrefixLength uint
 }
 
 // Creates a new forwarding table.
-func NewFowardingTable(optimizeForIPv4 bool, defaultPrefixLength uint) *FT {
-	return &FT{
-		tree:                radix.New(),
-		optimizeForIPv4:     optimizeForIPv4,
-		defaultPrefixLength: defaultPrefixLength,
+func NewFowardingTable(optimizeForIPv4 bool, ipv4PrefixLength uint, ipv6PrefixLength uint) *FT {
+	f := &FT{
+		tree:             NewTrie[*FTEntry](),
+		optimizeForIPv4:  optimizeForIPv4,
+		ipv4PrefixLength: ipv4PrefixLength,
+		ipv6PrefixLength: ipv6PrefixLength,
+	}
+	if optimizeForIPv4 {
+		f.tree4 = &trie4[*FTEntry]{}
 	}
+	return f
 }
 
 // Performs a longest prefix match to get the next hop of a given ip address. This
 // speciality is used in the routers.
 func (f *FT) Lookup(address *net.IP) (*FTEntry, bool, error) {
-	// The IP address is onverted into a IPv6 String.
-	key, err := IPToKey(address)
+	addr, err := AddrFromIP(address)
 	if err != nil {
 		return nil, false, err
 	}
-	_, item, found := f.tree.LongestPrefix(key)
-	if !found {
-		return nil, false, nil
-	}
+	_, entry, found := f.LookupAddr(addr)
+	return entry, found, nil
+}
 
-	if setObj, ok := item.(*FTEntry); !ok {
-		return nil, false, errors.New("the expected type of NFMap is not an NFMap")
-	} else {
-		return setObj, true, nil
+// Performs a longest prefix match on the address and returns the matched
+// prefix with its entry.
+func (f *FT) LookupAddr(address netip.Addr) (netip.Prefix, *FTEntry, bool) {
+	address = address.Unmap()
+	if f.tree4 != nil && address.Is4() {
+		if prefix, entry, found := f.tree4.LongestPrefix(address); found {
+			return prefix, entry, true
+		}
+		// The IPv6 prefixes covering ::ffff:0:0/96 also cover the IPv4 ones.
 	}
+	return f.tree.LongestPrefix(address)
 }
 
 // Check if the given network is already registered.
 func (f *FT) Contains(network *net.IPNet) (*FTEntry, bool, error) {
-	// The IP address is onverted into a IPv6 String.
-	key, err := NetworkToKey(network)
+	prefix, err := PrefixFromNetwork(network)
 	if err != nil {
 		return nil, false, err
 	}
+	entry, found := f.ContainsPrefix(prefix)
+	return entry, found, nil
+}
 
-	item, found := f.tree.Get(key)
-	if !found {
-		return nil, false, nil
-	}
-
-	if setObj, ok := item.(*FTEntry); !ok {
-		return nil, false, errors.New("the expected type of NFMap is not an NFMap")
-	} else {
-		return setObj, true, nil
-	}
+// Check if the given prefix is already registered.
+func (f *FT) ContainsPrefix(prefix netip.Prefix) (*FTEntry, bool) {
+	return f.get(prefix)
 }
 
 // Inserts the nexthop address to the reverse forwarding table.
@@ -80,87 +90,143 @@ func (f *FT) Insert(network *net.IPNet, nexthop *net.IP) error {
 		return ErrGivenAddressNil
 	}
 
-	entry, found, err := f.Contains(network)
+	prefix, err := PrefixFromNetwork(network)
 	if err != nil {
 		return err
 	}
+	nexthopAddr, err := AddrFromIP(nexthop)
+	if err != nil {
+		return err
+	}
+
+	f.InsertPrefix(prefix, nexthopAddr)
+	return nil
+}
 
+// Inserts the nexthop address for the given prefix.
+func (f *FT) InsertPrefix(prefix netip.Prefix, nexthop netip.Addr) {
+	f.InsertObservation(prefix, nexthop, Observation{Count: 1})
+}
+
+// Inserts the nexthop address for the given prefix and merges the observation
+// into the one of the nexthop.
+func (f *FT) InsertObservation(prefix netip.Prefix, nexthop netip.Addr, o Observation) {
+	entry, found := f.get(prefix)
 	if !found {
 		entry = newFTEntry(DefaultEntrySize)
+		f.insert(prefix, entry)
 	}
 
-	entry.Add(nexthop)
+	entry.Observe(nexthop, o)
+}
+
+// Adds the prefixes of the other table into this one, the next hop sets of
+// the common prefixes are joined.
+func (f *FT) Merge(other *FT) {
+	other.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+		if existing, found := f.get(prefix); found {
+			existing.Merge(entry)
+		} else {
+			f.insert(prefix, entry.Clone())
+		}
+		return true
+	})
+}
 
-	key, err := NetworkToKey(network)
+// Inserts the nexthop address for the network of the destination address,
+// using the default prefix length of its family.
+func (f *FT) InsertAddr(dst netip.Addr, nexthop netip.Addr) error {
+	prefix, err := DefaultPrefix(dst, f.ipv4PrefixLength, f.ipv6PrefixLength)
 	if err != nil {
 		return err
 	}
-	f.tree.Insert(key, entry)
+	f.InsertPrefix(prefix, nexthop)
 	return nil
 }
 
-// Converts the forwarding table into a String
-func (f *FT) String() string {
-	var sb strings.Builder
+// Returns the IPv4 and IPv6 prefix lengths used when inserting a destination
+// address.
+func (f *FT) DefaultPrefixLengths() (uint, uint) {
+	return f.ipv4PrefixLength, f.ipv6PrefixLength
+}
 
-	for networkKey, entry := range f.tree.ToMap() {
-		networkPrefix, err := KeyToIP(networkKey)
-		if err != nil {
-			panic(err)
-		}
-		network, err := IPToNetwork(networkPrefix, int(f.defaultPrefixLength))
-		if err != nil {
-			log.Printf("An error occured while printing: %v.\n", err)
-			continue
-		}
-		sb.WriteString(fmt.Sprintf("\t%v -> %v\n", network.String(), entry))
+// Returns the number of prefixes in the forwarding table.
+func (f *FT) Len() int {
+	if f.tree4 != nil {
+		return f.tree4.Len() + f.tree.Len()
 	}
-
-	return sb.String()
+	return f.tree.Len()
 }
 
-// FTEntry is an object that denotes the next hops of a router. It is a set because
-// there can be ECPM and load balancing enabled.
-type FTEntry struct {
-	// IPs denote an array of net.IP addresses. Thanks to them we can perform
-	// some dset operations like add, contains etc.
-	dset []*net.IP
+// Walks the prefixes of the forwarding table in order. Returning false from fn
+// stops the walk.
+func (f *FT) Walk(fn func(prefix netip.Prefix, entry *FTEntry) bool) {
+	if f.tree4 == nil {
+		f.tree.Walk(fn)
+		return
+	}
+
+	// The IPv4 prefixes are walked where they would be in the 128 bit key
+	// space, right before the first IPv6 prefix after ::ffff:0:0/96.
+	walked4 := false
+	ok := true
+	visit := func(prefix netip.Prefix, entry *FTEntry) bool {
+		ok = fn(prefix, entry)
+		return ok
+	}
+	f.tree.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+		if !walked4 && prefix.Addr().Compare(lastIPv4Mapped) > 0 {
+			walked4 = true
+			if f.tree4.Walk(visit); !ok {
+				return false
+			}
+		}
+		return visit(prefix, entry)
+	})
+	if !walked4 && ok {
+		f.tree4.Walk(fn)
+	}
 }
 
-// Creates a new NHSet struct.
-func newFTEntry(size uint) *FTEntry {
-	return &FTEntry{
-		dset: make([]*net.IP, 0, size),
+// The last address of ::ffff:0:0/96.
+var lastIPv4Mapped = netip.AddrFrom16([16]byte{10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff})
+
+// Returns the entry of the exact prefix.
+func (f *FT) get(prefix netip.Prefix) (*FTEntry, bool) {
+	prefix = UnmapPrefix(prefix)
+	if f.tree4 != nil && prefix.Addr().Is4() {
+		return f.tree4.Get(prefix)
 	}
+	return f.tree.Get(prefix)
 }
 
-// Adds the ip address if it is not already in the set.
-func (n *FTEntry) Add(ip *net.IP) {
-	if !n.Contains(ip) {
-		n.dset = append(n.dset, ip)
+// Inserts or replaces the entry of the prefix.
+func (f *FT) insert(prefix netip.Prefix, entry *FTEntry) {
+	prefix = UnmapPrefix(prefix)
+	if f.tree4 != nil && prefix.Addr().Is4() {
+		f.tree4.Insert(prefix, entry)
+		return
 	}
+	f.tree.Insert(prefix, entry)
 }
 
-// Checks if the given IP address is already in the set.
-func (n *FTEntry) Contains(ip *net.IP) bool {
-	for _, existing := range n.dset {
-		if existing.Equal(*ip) {
-			return true
-		}
+// Removes the prefix, returns false if it was not there.
+func (f *FT) delete(prefix netip.Prefix) bool {
+	prefix = UnmapPrefix(prefix)
+	if f.tree4 != nil && prefix.Addr().Is4() {
+		return f.tree4.Delete(prefix)
 	}
-	return false
+	return f.tree.Delete(prefix)
 }
 
-// ToString method returns a string representation of all IPs
-func (ft *FTEntry) String() string {
+// Converts the forwarding table into a String
+func (f *FT) String() string {
 	var sb strings.Builder
-	sb.WriteString("{")
-	for i, ip := range ft.dset {
-		sb.WriteString(ip.String())
-		if i < len(ft.dset)-1 {
-			sb.WriteString(", ")
-		}
-	}
-	sb.WriteString("}")
+
+	f.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+		sb.WriteString(fmt.Sprintf("\t%v -> %v\n", prefix, entry))
+		return true
+	})
+
 	return sb.String()
 }
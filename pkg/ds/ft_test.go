@@ -0,0 +1,103 @@
+package ds
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// Returns a random address, IPv6 with the given probability.
+func randomAddr(r *rand.Rand, ipv6Fraction float64) netip.Addr {
+	if r.Float64() < ipv6Fraction {
+		var b [16]byte
+		b[0], b[1] = 0x20, 0x01
+		r.Read(b[2:8])
+		return netip.AddrFrom16(b)
+	}
+	return netip.AddrFrom4([4]byte{byte(1 + r.Intn(8)), byte(r.Intn(4)), byte(r.Intn(256)), byte(r.Intn(256))})
+}
+
+func TestDualStackLookupsMatchIPv4FastPath(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	dualStack := NewFIB(16, false, 24, 48)
+	optimized := NewFIB(16, true, 24, 48)
+
+	routers := make([]netip.Addr, 8)
+	for i := range routers {
+		routers[i] = randomAddr(r, 0.5)
+	}
+	for i := 0; i < 20000; i++ {
+		near, dst, far := routers[r.Intn(len(routers))], randomAddr(r, 0.4), randomAddr(r, 0.3)
+		for _, f := range []*FIB{dualStack, optimized} {
+			if err := f.InsertAddr(near, dst, far); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Aggregated, shorter and IPv4-mapped prefixes cover the IPv4 ones from
+	// the 128 bit trie of the optimized tables.
+	for _, prefix := range []string{"1.0.0.0/8", "::ffff:2.0.0.0/104", "2001::/16", "::/0"} {
+		for _, f := range []*FIB{dualStack, optimized} {
+			f.InsertPrefix(routers[0], netip.MustParsePrefix(prefix), routers[1])
+		}
+	}
+
+	if got, want := exportString(t, optimized), exportString(t, dualStack); got != want {
+		t.Fatalf("the optimized FIB exports differently than the dual-stack one")
+	}
+
+	for i := 0; i < 20000; i++ {
+		near := routers[r.Intn(len(routers))]
+		dst := randomAddr(r, 0.5)
+		if i%4 == 0 && dst.Is4() {
+			dst = netip.AddrFrom16(dst.As16())
+		}
+
+		prefix, entry, found := dualStack.LookupAddr(near, dst)
+		optimizedPrefix, optimizedEntry, optimizedFound := optimized.LookupAddr(near, dst)
+		if found != optimizedFound || prefix != optimizedPrefix || (found && !entry.Equal(optimizedEntry)) {
+			t.Fatalf("LookupAddr(%v, %v) = %v %v %v on the dual-stack FIB, %v %v %v on the optimized one",
+				near, dst, prefix, entry, found, optimizedPrefix, optimizedEntry, optimizedFound)
+		}
+		if found && dst.Unmap().Is4() != prefix.Addr().Is4() && prefix.Bits() != 0 {
+			t.Fatalf("LookupAddr(%v, %v) matched %v of the other family", near, dst, prefix)
+		}
+	}
+}
+
+func TestInsertAddrUsesTheDefaultPrefixLengths(t *testing.T) {
+	for _, optimize := range []bool{false, true} {
+		ft := NewFowardingTable(optimize, 20, 40)
+		ft.InsertAddr(netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("10.0.0.1"))
+		ft.InsertAddr(netip.MustParseAddr("::ffff:9.9.9.9"), netip.MustParseAddr("10.0.0.1"))
+		ft.InsertAddr(netip.MustParseAddr("2a00:1:2:3::1"), netip.MustParseAddr("10.0.0.1"))
+
+		for _, prefix := range []string{"8.8.0.0/20", "9.9.0.0/20", "2a00:1::/40"} {
+			if _, found := ft.ContainsPrefix(netip.MustParsePrefix(prefix)); !found {
+				t.Errorf("optimize=%v: %v not found in\n%v", optimize, prefix, ft)
+			}
+		}
+		if ft.Len() != 3 {
+			t.Errorf("optimize=%v: %v prefixes, want 3", optimize, ft.Len())
+		}
+	}
+}
+
+func TestUnmapPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"::ffff:8.8.8.0/120", "8.8.8.0/24"},
+		{"::ffff:0.0.0.0/96", "0.0.0.0/0"},
+		{"::ffff:8.8.8.8/128", "8.8.8.8/32"},
+		{"::ffff:0.0.0.0/95", "::ffff:0.0.0.0/95"},
+		{"8.8.8.0/24", "8.8.8.0/24"},
+		{"2001:db8::/32", "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		if got := UnmapPrefix(netip.MustParsePrefix(tt.prefix)); got != netip.MustParsePrefix(tt.want) {
+			t.Errorf("UnmapPrefix(%v) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
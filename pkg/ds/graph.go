@@ -0,0 +1,161 @@
+package ds
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// GraphFormat is the file format of an exported graph.
+type GraphFormat int
+
+const (
+	GraphML GraphFormat = iota
+	GraphDOT
+	// GraphEdgeList writes one edge per line, the source, target and the
+	// attributes separated by spaces.
+	GraphEdgeList
+)
+
+var ErrUnknownGraphFormat = errors.New("unknown graph format")
+
+// Parses the name of a graph format.
+func ParseGraphFormat(name string) (GraphFormat, error) {
+	switch strings.ToLower(name) {
+	case "graphml":
+		return GraphML, nil
+	case "dot", "graphviz":
+		return GraphDOT, nil
+	case "edgelist", "edges":
+		return GraphEdgeList, nil
+	default:
+		return GraphML, fmt.Errorf("%w: %v", ErrUnknownGraphFormat, name)
+	}
+}
+
+// GraphEdge is a directed near to far router adjacency.
+type GraphEdge struct {
+	Source netip.Addr
+	Target netip.Addr
+	// Number of prefixes the source routes over the edge.
+	Prefixes int
+	// Number of records supporting the edge over all the prefixes.
+	Count uint64
+}
+
+// Graph is the router-level directed graph of a FIB, every near and far
+// address pair of the FIB is collapsed into a single edge.
+type Graph struct {
+	// Every address, with true for the ones that have a forwarding table.
+	Nodes map[netip.Addr]bool
+	Edges map[[2]netip.Addr]*GraphEdge
+}
+
+// Collapses the FIB into its router-level graph.
+func NewGraph(f *FIB) *Graph {
+	g := &Graph{
+		Nodes: make(map[netip.Addr]bool, len(f.fibs)),
+		Edges: make(map[[2]netip.Addr]*GraphEdge),
+	}
+
+	for nearAddress, ft := range f.fibs {
+		g.Nodes[nearAddress] = true
+		ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+			entry.Walk(func(farAddress netip.Addr, o Observation) bool {
+				if _, found := g.Nodes[farAddress]; !found {
+					g.Nodes[farAddress] = false
+				}
+				key := [2]netip.Addr{nearAddress, farAddress}
+				edge, found := g.Edges[key]
+				if !found {
+					edge = &GraphEdge{Source: nearAddress, Target: farAddress}
+					g.Edges[key] = edge
+				}
+				edge.Prefixes++
+				edge.Count += o.Count
+				return true
+			})
+			return true
+		})
+	}
+	return g
+}
+
+// Returns the addresses in order.
+func (g *Graph) SortedNodes() []netip.Addr {
+	nodes := make([]netip.Addr, 0, len(g.Nodes))
+	for address := range g.Nodes {
+		nodes = append(nodes, address)
+	}
+	slices.SortFunc(nodes, netip.Addr.Compare)
+	return nodes
+}
+
+// Returns the edges ordered by their source and target.
+func (g *Graph) SortedEdges() []*GraphEdge {
+	edges := make([]*GraphEdge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *GraphEdge) int {
+		return cmp.Or(a.Source.Compare(b.Source), a.Target.Compare(b.Target))
+	})
+	return edges
+}
+
+// Writes the graph in the given format, the nodes and edges are ordered.
+func (g *Graph) Write(w io.Writer, format GraphFormat) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	switch format {
+	case GraphML:
+		g.writeGraphML(bw)
+	case GraphDOT:
+		g.writeDOT(bw)
+	case GraphEdgeList:
+		g.writeEdgeList(bw)
+	default:
+		return ErrUnknownGraphFormat
+	}
+	return bw.Flush()
+}
+
+func (g *Graph) writeGraphML(w *bufio.Writer) {
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="router" for="node" attr.name="router" attr.type="boolean"/>
+  <key id="prefixes" for="edge" attr.name="prefixes" attr.type="int"/>
+  <key id="count" for="edge" attr.name="count" attr.type="long"/>
+  <graph id="fib" edgedefault="directed">
+`)
+	for _, address := range g.SortedNodes() {
+		fmt.Fprintf(w, "    <node id=\"%v\"><data key=\"router\">%v</data></node>\n", address, g.Nodes[address])
+	}
+	for _, edge := range g.SortedEdges() {
+		fmt.Fprintf(w, "    <edge source=\"%v\" target=\"%v\"><data key=\"prefixes\">%v</data><data key=\"count\">%v</data></edge>\n",
+			edge.Source, edge.Target, edge.Prefixes, edge.Count)
+	}
+	w.WriteString("  </graph>\n</graphml>\n")
+}
+
+func (g *Graph) writeDOT(w *bufio.Writer) {
+	w.WriteString("digraph fib {\n")
+	for _, address := range g.SortedNodes() {
+		fmt.Fprintf(w, "  \"%v\" [router=%v];\n", address, g.Nodes[address])
+	}
+	for _, edge := range g.SortedEdges() {
+		fmt.Fprintf(w, "  \"%v\" -> \"%v\" [prefixes=%v, count=%v];\n", edge.Source, edge.Target, edge.Prefixes, edge.Count)
+	}
+	w.WriteString("}\n")
+}
+
+func (g *Graph) writeEdgeList(w *bufio.Writer) {
+	w.WriteString("# source target prefixes count\n")
+	for _, edge := range g.SortedEdges() {
+		fmt.Fprintf(w, "%v %v %v %v\n", edge.Source, edge.Target, edge.Prefixes, edge.Count)
+	}
+}
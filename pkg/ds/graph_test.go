@@ -0,0 +1,112 @@
+package ds
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// A FIB with two routers, 10.0.0.1 reaches 10.0.0.2 over two prefixes, twice
+// for one of them, and the far address 10.0.0.3 has no forwarding table.
+func testGraphFIB() *FIB {
+	return testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.2", "8.8.8.0/24", "10.0.0.3"},
+	)
+}
+
+func TestParseGraphFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format GraphFormat
+		err    error
+	}{
+		{"graphml", GraphML, nil},
+		{"GraphML", GraphML, nil},
+		{"dot", GraphDOT, nil},
+		{"graphviz", GraphDOT, nil},
+		{"edgelist", GraphEdgeList, nil},
+		{"edges", GraphEdgeList, nil},
+		{"gexf", GraphML, ErrUnknownGraphFormat},
+		{"", GraphML, ErrUnknownGraphFormat},
+	}
+	for _, tt := range tests {
+		format, err := ParseGraphFormat(tt.name)
+		if !errors.Is(err, tt.err) || format != tt.format {
+			t.Errorf("ParseGraphFormat(%q) = %v, %v, want %v, %v", tt.name, format, err, tt.format, tt.err)
+		}
+	}
+}
+
+func TestGraphWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		format GraphFormat
+		want   string
+	}{
+		{"graphml", GraphML, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="router" for="node" attr.name="router" attr.type="boolean"/>
+  <key id="prefixes" for="edge" attr.name="prefixes" attr.type="int"/>
+  <key id="count" for="edge" attr.name="count" attr.type="long"/>
+  <graph id="fib" edgedefault="directed">
+    <node id="10.0.0.1"><data key="router">true</data></node>
+    <node id="10.0.0.2"><data key="router">true</data></node>
+    <node id="10.0.0.3"><data key="router">false</data></node>
+    <edge source="10.0.0.1" target="10.0.0.2"><data key="prefixes">2</data><data key="count">3</data></edge>
+    <edge source="10.0.0.1" target="10.0.0.3"><data key="prefixes">1</data><data key="count">1</data></edge>
+    <edge source="10.0.0.2" target="10.0.0.3"><data key="prefixes">1</data><data key="count">1</data></edge>
+  </graph>
+</graphml>
+`},
+		{"dot", GraphDOT, `digraph fib {
+  "10.0.0.1" [router=true];
+  "10.0.0.2" [router=true];
+  "10.0.0.3" [router=false];
+  "10.0.0.1" -> "10.0.0.2" [prefixes=2, count=3];
+  "10.0.0.1" -> "10.0.0.3" [prefixes=1, count=1];
+  "10.0.0.2" -> "10.0.0.3" [prefixes=1, count=1];
+}
+`},
+		{"edge list", GraphEdgeList, `# source target prefixes count
+10.0.0.1 10.0.0.2 2 3
+10.0.0.1 10.0.0.3 1 1
+10.0.0.2 10.0.0.3 1 1
+`},
+	}
+	g := NewGraph(testGraphFIB())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := g.Write(&sb, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != tt.want {
+				t.Errorf("got\n%v\nwant\n%v", sb.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphWriteUnknownFormat(t *testing.T) {
+	var sb strings.Builder
+	if err := NewGraph(testGraphFIB()).Write(&sb, GraphFormat(-1)); !errors.Is(err, ErrUnknownGraphFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownGraphFormat)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("wrote %q for an unknown format", sb.String())
+	}
+}
+
+func TestGraphEmpty(t *testing.T) {
+	var sb strings.Builder
+	if err := NewGraph(testFIB()).Write(&sb, GraphEdgeList); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# source target prefixes count\n"; sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
@@ -0,0 +1,75 @@
+package ds
+
+import (
+	"hash/maphash"
+	"net/netip"
+)
+
+// ShardedFIB splits the routers of a FIB into shards by the hash of the near
+// address. The shards share nothing, so records of different shards can be
+// inserted concurrently as long as every shard is written by a single
+// goroutine. A router always lands in the same shard, therefore the shards are
+// disjoint and can be joined back into a single FIB for free.
+type ShardedFIB struct {
+	shards []*FIB
+	seed   maphash.Seed
+}
+
+// Creates a new sharded forwarding information base. The size is the initial
+// number of routers of every shard.
+func NewShardedFIB(numShards uint, size uint, optimizeForIPv4 bool, ipv4PrefixLength uint, ipv6PrefixLength uint) *ShardedFIB {
+	if numShards == 0 {
+		numShards = 1
+	}
+	shards := make([]*FIB, numShards)
+	for i := range shards {
+		shards[i] = NewFIB(size, optimizeForIPv4, ipv4PrefixLength, ipv6PrefixLength)
+	}
+	return &ShardedFIB{
+		shards: shards,
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+// Returns the number of shards.
+func (s *ShardedFIB) NumShards() int {
+	return len(s.shards)
+}
+
+// Returns the shard the router address belongs to.
+func (s *ShardedFIB) Shard(address netip.Addr) int {
+	b := address.Unmap().As16()
+	return int(maphash.Bytes(s.seed, b[:]) % uint64(len(s.shards)))
+}
+
+// Inserts a new forwarding info for the router address. Only the goroutine
+// writing the shard of the address may call it.
+func (s *ShardedFIB) InsertPrefix(address netip.Addr, prefix netip.Prefix, nexthop netip.Addr) {
+	s.InsertObservation(address, prefix, nexthop, Observation{Count: 1})
+}
+
+// Inserts a new forwarding info for the router address with what is known
+// about the record supporting it. Only the goroutine writing the shard of the
+// address may call it.
+func (s *ShardedFIB) InsertObservation(address netip.Addr, prefix netip.Prefix, nexthop netip.Addr, o Observation) {
+	s.shards[s.Shard(address)].InsertObservation(address, prefix, nexthop, o)
+}
+
+// Joins the shards into a single FIB. The sharded FIB must not be used after.
+func (s *ShardedFIB) FIB() *FIB {
+	size := 0
+	for _, shard := range s.shards {
+		size += shard.Len()
+	}
+
+	first := s.shards[0]
+	f := NewFIB(uint(size), first.optimizeForIPv4, first.ipv4PrefixLength, first.ipv6PrefixLength)
+	for _, shard := range s.shards {
+		for address, ft := range shard.fibs {
+			f.fibs[address] = ft
+		}
+	}
+
+	s.shards = nil
+	return f
+}
@@ -0,0 +1,71 @@
+package ds
+
+import (
+	"math/rand"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+func TestShardedFIBMatchesFIB(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	records := testRecords(r, 5000)
+
+	for _, numShards := range []uint{0, 1, 3, 8} {
+		sharded := NewShardedFIB(numShards, 4, true, 16, 32)
+		if want := int(max(numShards, 1)); sharded.NumShards() != want {
+			t.Fatalf("%v shards asked, got %v, want %v", numShards, sharded.NumShards(), want)
+		}
+
+		// Every shard is written by its own goroutine, as the insert
+		// workers of the build do.
+		var wg sync.WaitGroup
+		for shard := 0; shard < sharded.NumShards(); shard++ {
+			wg.Add(1)
+			go func(shard int) {
+				defer wg.Done()
+				for _, record := range records {
+					if sharded.Shard(record.near) != shard {
+						continue
+					}
+					prefix, err := DefaultPrefix(record.dst, 16, 32)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					sharded.InsertObservation(record.near, prefix, record.far, record.observation)
+				}
+			}(shard)
+		}
+		wg.Wait()
+
+		if got, want := exportString(t, sharded.FIB()), exportString(t, buildTestFIB(t, true, records)); got != want {
+			t.Errorf("%v shards: the sharded FIB exports\n%v\nwant\n%v", numShards, got, want)
+		}
+	}
+}
+
+func TestShardedFIBShard(t *testing.T) {
+	sharded := NewShardedFIB(16, 1, false, 24, 48)
+	used := make(map[int]bool)
+	for i := 0; i < 256; i++ {
+		address := netip.AddrFrom4([4]byte{10, 0, 0, byte(i)})
+		shard := sharded.Shard(address)
+		if shard < 0 || shard >= 16 {
+			t.Fatalf("Shard(%v) = %v, out of range", address, shard)
+		}
+		if mapped := sharded.Shard(netip.AddrFrom16(address.As16())); mapped != shard {
+			t.Errorf("the mapped %v is in shard %v, want %v", address, mapped, shard)
+		}
+		used[shard] = true
+	}
+	if len(used) < 8 {
+		t.Errorf("256 routers use only %v of the 16 shards", len(used))
+	}
+
+	sharded.InsertPrefix(netip.MustParseAddr("::ffff:10.0.0.1"), netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.2"))
+	f := sharded.FIB()
+	if _, entry, found := f.LookupAddr(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("8.8.8.8")); !found || entry.Len() != 1 {
+		t.Errorf("the joined FIB misses the mapped router")
+	}
+}
@@ -0,0 +1,389 @@
+package ds
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"time"
+)
+
+// The snapshot is a binary serialization of the FIB. It starts with the magic
+// bytes and the format version, followed by the header and the routers:
+//
+//	magic | version | header | #routers | router... | #router ids | router id... | far routers
+//	router: near address | #networks | network...
+//	network: prefix address | prefix length | #far addresses | far address...
+//	far address: address | count | first seen | last seen | first round | last round
+//	router id: address | node identifier
+//
+// Addresses are always written as 16 bytes, counts, lengths, unix times and
+// rounds as uvarints. Version 1 has a single default prefix length, version 2
+// has one for IPv4 and one for IPv6, version 3 adds the observations of the
+// far addresses and version 4 the node identifiers of the collapsed routers.
+// The far addresses of the older versions are loaded as observed once.
+const (
+	SnapshotMagic   = "RIFIBSNP"
+	SnapshotVersion = 4
+)
+
+var (
+	ErrNotASnapshot               = errors.New("input is not a FIB snapshot")
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported FIB snapshot version")
+	ErrCorruptSnapshot            = errors.New("corrupt FIB snapshot")
+)
+
+// Smallest encoded sizes of a router, a network and a far address, used to
+// reject the counts the rest of the input cannot hold.
+const (
+	minRouterSize     = 16 + 1
+	minNetworkSize    = 16 + 1 + 1
+	minFarAddressSize = 16
+	minObservedSize   = 16 + 5
+	minRouterIDSize   = 16 + 1
+)
+
+// Largest number of routers allocated up front, the rest grow as they are
+// read.
+const maxPreallocatedRouters = 1 << 20
+
+// SnapshotHeader records where a snapshot comes from and how it was built.
+type SnapshotHeader struct {
+	Version          uint16
+	Source           string
+	Records          uint64
+	Created          time.Time
+	OptimizeForIPv4  bool
+	IPv4PrefixLength uint
+	IPv6PrefixLength uint
+}
+
+// Saves the FIB into the writer. The build parameters of the header are taken
+// from the FIB itself, the rest is written as given. The routers and their
+// prefixes are written in order, so the same FIB and header always give the
+// same bytes.
+func (f *FIB) Save(w io.Writer, header *SnapshotHeader) error {
+	if header == nil {
+		header = &SnapshotHeader{}
+	}
+	h := *header
+	h.Version = SnapshotVersion
+	h.OptimizeForIPv4 = f.optimizeForIPv4
+	h.IPv4PrefixLength = f.ipv4PrefixLength
+	h.IPv6PrefixLength = f.ipv6PrefixLength
+	if h.Created.IsZero() {
+		h.Created = time.Now()
+	}
+
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	sw.writeBytes([]byte(SnapshotMagic))
+	sw.writeUint16(h.Version)
+	sw.writeString(h.Source)
+	sw.writeUvarint(h.Records)
+	sw.writeUvarint(uint64(h.Created.Unix()))
+	sw.writeBool(h.OptimizeForIPv4)
+	sw.writeUvarint(uint64(h.IPv4PrefixLength))
+	sw.writeUvarint(uint64(h.IPv6PrefixLength))
+
+	sw.writeUvarint(uint64(len(f.fibs)))
+	for _, nearAddress := range f.addrs(true) {
+		ft := f.fibs[nearAddress]
+		sw.writeAddr(nearAddress)
+		sw.writeUvarint(uint64(ft.Len()))
+
+		ft.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+			key, length := keyFromPrefix(prefix)
+			sw.writeAddr(key.addr())
+			sw.writeUvarint(uint64(length))
+			sw.writeUvarint(uint64(entry.Len()))
+			entry.walk(func(farAddress netip.Addr, o observation) bool {
+				sw.writeAddr(farAddress)
+				sw.writeUvarint(o.count)
+				sw.writeUvarint(uint64(max(o.firstSeen, 0)))
+				sw.writeUvarint(uint64(max(o.lastSeen, 0)))
+				sw.writeUvarint(uint64(o.firstRound))
+				sw.writeUvarint(uint64(o.lastRound))
+				return sw.err == nil
+			})
+			return sw.err == nil
+		})
+		if sw.err != nil {
+			return sw.err
+		}
+	}
+
+	sw.writeUvarint(uint64(len(f.routerIDs)))
+	for _, address := range slices.SortedFunc(maps.Keys(f.routerIDs), netip.Addr.Compare) {
+		sw.writeAddr(address)
+		sw.writeString(f.routerIDs[address])
+	}
+	sw.writeBool(f.farRouters)
+
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// Loads a FIB saved with Save from the reader. When the size of the input is
+// known, as for files and byte readers, the counts larger than what the rest
+// of it can hold are rejected early.
+func Load(r io.Reader) (*FIB, *SnapshotHeader, error) {
+	sr := &snapshotReader{r: bufio.NewReader(r), size: inputSize(r)}
+
+	magic := sr.readBytes(len(SnapshotMagic))
+	if sr.err != nil || string(magic) != SnapshotMagic {
+		return nil, nil, ErrNotASnapshot
+	}
+
+	h := &SnapshotHeader{}
+	h.Version = sr.readUint16()
+	if sr.err == nil && (h.Version < 1 || h.Version > SnapshotVersion) {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedSnapshotVersion, h.Version)
+	}
+	h.Source = sr.readString()
+	h.Records = sr.readUvarint()
+	h.Created = time.Unix(int64(sr.readUvarint()), 0)
+	h.OptimizeForIPv4 = sr.readBool()
+	h.IPv4PrefixLength = uint(sr.readUvarint())
+	h.IPv6PrefixLength = h.IPv4PrefixLength
+	if h.Version >= 2 {
+		h.IPv6PrefixLength = uint(sr.readUvarint())
+	}
+
+	if h.IPv4PrefixLength > 32 || h.IPv6PrefixLength > 128 {
+		return nil, nil, fmt.Errorf("%w: invalid prefix lengths %v/%v", ErrCorruptSnapshot, h.IPv4PrefixLength, h.IPv6PrefixLength)
+	}
+	farAddressSize := minObservedSize
+	if h.Version < 3 {
+		farAddressSize = minFarAddressSize
+	}
+
+	numRouters := sr.readCount(minRouterSize)
+	if sr.err != nil {
+		return nil, nil, sr.err
+	}
+
+	f := NewFIB(uint(min(numRouters, maxPreallocatedRouters)), h.OptimizeForIPv4, h.IPv4PrefixLength, h.IPv6PrefixLength)
+	for i := uint64(0); i < numRouters; i++ {
+		nearAddress := sr.readAddr()
+		numNetworks := sr.readCount(minNetworkSize)
+		if sr.err != nil {
+			return nil, nil, sr.err
+		}
+
+		ft := NewFowardingTable(h.OptimizeForIPv4, h.IPv4PrefixLength, h.IPv6PrefixLength)
+		for j := uint64(0); j < numNetworks; j++ {
+			networkPrefix := sr.readAddr()
+			prefixLength := sr.readUvarint()
+			numFarAddresses := sr.readCount(farAddressSize)
+			if sr.err != nil {
+				return nil, nil, sr.err
+			}
+			if prefixLength > 128 {
+				return nil, nil, fmt.Errorf("%w: invalid prefix length %v", ErrCorruptSnapshot, prefixLength)
+			}
+			prefix := keyFromAddr(networkPrefix).prefix(int(prefixLength))
+
+			entry := newFTEntry(uint(min(numFarAddresses, DefaultEntrySize)))
+			for k := uint64(0); k < numFarAddresses; k++ {
+				farAddress := sr.readAddr()
+				if sr.err != nil {
+					return nil, nil, sr.err
+				}
+				if h.Version < 3 {
+					entry.AddAddr(farAddress)
+					continue
+				}
+				entry.add(farAddress, observation{
+					count:      sr.readUvarint(),
+					firstSeen:  int64(sr.readUvarint()),
+					lastSeen:   int64(sr.readUvarint()),
+					firstRound: uint32(sr.readUvarint()),
+					lastRound:  uint32(sr.readUvarint()),
+				})
+			}
+			if sr.err != nil {
+				return nil, nil, sr.err
+			}
+			ft.insert(prefix, entry)
+		}
+
+		f.fibs[nearAddress] = ft
+	}
+
+	if h.Version >= 4 {
+		numRouterIDs := sr.readCount(minRouterIDSize)
+		for i := uint64(0); i < numRouterIDs; i++ {
+			address := sr.readAddr()
+			id := sr.readString()
+			if sr.err != nil {
+				return nil, nil, sr.err
+			}
+			f.setRouterID(address, id)
+		}
+		f.farRouters = sr.readBool()
+		if sr.err != nil {
+			return nil, nil, sr.err
+		}
+	}
+
+	return f, h, nil
+}
+
+// snapshotWriter writes the primitive types of the snapshot and remembers
+// the first error so the callers can check it once.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (s *snapshotWriter) writeBytes(b []byte) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.w.Write(b)
+}
+
+func (s *snapshotWriter) writeUvarint(v uint64) {
+	n := binary.PutUvarint(s.buf[:], v)
+	s.writeBytes(s.buf[:n])
+}
+
+func (s *snapshotWriter) writeUint16(v uint16) {
+	binary.BigEndian.PutUint16(s.buf[:], v)
+	s.writeBytes(s.buf[:2])
+}
+
+func (s *snapshotWriter) writeBool(v bool) {
+	if v {
+		s.writeBytes([]byte{1})
+	} else {
+		s.writeBytes([]byte{0})
+	}
+}
+
+func (s *snapshotWriter) writeString(v string) {
+	s.writeUvarint(uint64(len(v)))
+	s.writeBytes([]byte(v))
+}
+
+func (s *snapshotWriter) writeAddr(addr netip.Addr) {
+	b := addr.As16()
+	s.writeBytes(b[:])
+}
+
+// snapshotReader is the counterpart of snapshotWriter. It counts the bytes it
+// reads to check the counts against the size of the input, -1 if unknown.
+type snapshotReader struct {
+	r    *bufio.Reader
+	size int64
+	read int64
+	err  error
+}
+
+// Returns the number of bytes left in the reader, -1 if it is unknown.
+func inputSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+func (s *snapshotReader) readBytes(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		s.err = fmt.Errorf("truncated snapshot: %w", err)
+		return nil
+	}
+	s.read += int64(n)
+	return b
+}
+
+// Reads a byte for binary.ReadUvarint.
+func (s *snapshotReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.read++
+	}
+	return b, err
+}
+
+func (s *snapshotReader) readUvarint() uint64 {
+	if s.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(s)
+	if err != nil {
+		s.err = fmt.Errorf("truncated snapshot: %w", err)
+		return 0
+	}
+	return v
+}
+
+// Reads the number of the following items, each taking at least itemSize
+// bytes. Counts larger than the rest of the input can hold are an error.
+func (s *snapshotReader) readCount(itemSize int) uint64 {
+	n := s.readUvarint()
+	if s.err != nil || s.size < 0 {
+		return n
+	}
+	if remaining := uint64(max(s.size-s.read, 0)); n > remaining/uint64(itemSize) {
+		s.err = fmt.Errorf("%w: count %v does not fit in the %v bytes left", ErrCorruptSnapshot, n, remaining)
+		return 0
+	}
+	return n
+}
+
+func (s *snapshotReader) readUint16() uint16 {
+	b := s.readBytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (s *snapshotReader) readBool() bool {
+	b := s.readBytes(1)
+	return b != nil && b[0] != 0
+}
+
+func (s *snapshotReader) readString() string {
+	n := s.readUvarint()
+	if s.err != nil {
+		return ""
+	}
+	if n > 1<<20 {
+		s.err = fmt.Errorf("string of length %v is too long for a snapshot", n)
+		return ""
+	}
+	return string(s.readBytes(int(n)))
+}
+
+func (s *snapshotReader) readAddr() netip.Addr {
+	b := s.readBytes(16)
+	if b == nil {
+		return netip.Addr{}
+	}
+	return netip.AddrFrom16([16]byte(b)).Unmap()
+}
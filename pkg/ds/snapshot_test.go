@@ -0,0 +1,170 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// Builds a FIB with observations, several routers, IPv4 and IPv6 prefixes.
+func testSnapshotFIB() *FIB {
+	f := NewFIB(4, true, 24, 48)
+	near := netip.MustParseAddr("10.0.0.1")
+	f.InsertObservation(near, netip.MustParsePrefix("8.8.8.0/24"), netip.MustParseAddr("10.0.0.2"),
+		NewObservation(time.Unix(1700000000, 0), 3))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.0.0/16"), netip.MustParseAddr("10.0.0.3"))
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.0.0/16"), netip.MustParseAddr("10.0.0.4"))
+	f.InsertPrefix(netip.MustParseAddr("2001:db8::1"), netip.MustParsePrefix("2a00::/48"), netip.MustParseAddr("2001:db8::2"))
+	for i := byte(0); i < 32; i++ {
+		f.InsertPrefix(netip.AddrFrom4([4]byte{10, 1, 0, i}), netip.MustParsePrefix("9.9.9.0/24"), near)
+	}
+	return f
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	f := testSnapshotFIB()
+	var buf bytes.Buffer
+	if err := f.Save(&buf, &SnapshotHeader{Source: "test", Records: 3}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, header, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != SnapshotVersion || header.Source != "test" || header.Records != 3 ||
+		!header.OptimizeForIPv4 || header.IPv4PrefixLength != 24 || header.IPv6PrefixLength != 48 {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if got, want := exportString(t, loaded), exportString(t, f); got != want {
+		t.Errorf("loaded FIB exports\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestSnapshotKeepsPrefixLengths(t *testing.T) {
+	for _, optimizeForIPv4 := range []bool{false, true} {
+		f := NewFIB(4, optimizeForIPv4, 24, 48)
+		near := netip.MustParseAddr("10.0.0.1")
+		far := netip.MustParseAddr("10.0.0.2")
+		// Destination addresses at the default lengths, prefixes at their own
+		// lengths and two /25 that aggregate into a /24.
+		for _, dst := range []string{"8.8.8.8", "::ffff:9.9.9.9", "2a00:1:2:3::1"} {
+			if err := f.InsertAddr(near, netip.MustParseAddr(dst), far); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, prefix := range []string{"8.8.0.0/20", "8.0.0.0/8", "7.7.7.0/25", "7.7.7.128/25", "2a00:1::/32", "2a00:1:2:3::/64", "::/0"} {
+			f.InsertPrefix(near, netip.MustParsePrefix(prefix), far)
+		}
+		f.Aggregate()
+
+		var buf bytes.Buffer
+		if err := f.Save(&buf, &SnapshotHeader{}); err != nil {
+			t.Fatal(err)
+		}
+		loaded, _, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `"near_addr","prefix","far_addr","count","first_seen","last_seen","first_round","last_round"
+"10.0.0.1","::/0","10.0.0.2","1","","","",""
+"10.0.0.1","7.7.7.0/24","10.0.0.2","2","","","",""
+"10.0.0.1","8.0.0.0/8","10.0.0.2","1","","","",""
+"10.0.0.1","8.8.0.0/20","10.0.0.2","1","","","",""
+"10.0.0.1","8.8.8.0/24","10.0.0.2","1","","","",""
+"10.0.0.1","9.9.9.0/24","10.0.0.2","1","","","",""
+"10.0.0.1","2a00:1::/32","10.0.0.2","1","","","",""
+"10.0.0.1","2a00:1:2::/48","10.0.0.2","1","","","",""
+"10.0.0.1","2a00:1:2:3::/64","10.0.0.2","1","","","",""
+`
+		if got := exportString(t, loaded); got != want {
+			t.Errorf("optimize=%v: loaded FIB exports\n%v\nwant\n%v", optimizeForIPv4, got, want)
+		}
+		for _, dst := range []string{"8.8.8.8", "8.8.15.1", "8.9.0.1", "7.7.7.200", "2a00:1:2:3::1", "2a00:1:2:4::1", "2a00:1:3::1", "2a01::1"} {
+			address := netip.MustParseAddr(dst)
+			prefix, _, _ := f.LookupAddr(near, address)
+			if got, _, _ := loaded.LookupAddr(near, address); got != prefix {
+				t.Errorf("optimize=%v: %v matches %v in the loaded FIB, want %v", optimizeForIPv4, dst, got, prefix)
+			}
+		}
+	}
+}
+
+func TestSnapshotIsDeterministic(t *testing.T) {
+	header := &SnapshotHeader{Source: "test", Created: time.Unix(1700000000, 0)}
+	var first bytes.Buffer
+	if err := testSnapshotFIB().Save(&first, header); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := testSnapshotFIB().Save(&buf, header); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), first.Bytes()) {
+			t.Fatal("saving the same FIB twice gives different snapshots")
+		}
+	}
+}
+
+// Returns the sorted CSV export of the FIB.
+func exportString(t *testing.T, f *FIB) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := f.Export(&buf, ExportOptions{Format: ExportCSV, Sorted: true}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// Returns the bytes of an empty snapshot header, ready for the router count.
+func snapshotHeaderBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(SnapshotMagic)
+	binary.Write(&buf, binary.BigEndian, uint16(SnapshotVersion))
+	buf.Write(binary.AppendUvarint(nil, 0)) // source
+	buf.Write(binary.AppendUvarint(nil, 0)) // records
+	buf.Write(binary.AppendUvarint(nil, 0)) // created
+	buf.WriteByte(0)                        // optimize for IPv4
+	buf.Write(binary.AppendUvarint(nil, 24))
+	buf.Write(binary.AppendUvarint(nil, 48))
+	return buf.Bytes()
+}
+
+func TestLoadCorruptSnapshot(t *testing.T) {
+	address := make([]byte, 16)
+	huge := binary.AppendUvarint(nil, 1<<62)
+	router := append(append(binary.AppendUvarint(nil, 1), address...), binary.AppendUvarint(nil, 1)...)
+	network := append(append([]byte{}, address...), 24)
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"huge router count", huge},
+		{"huge network count", append(append(binary.AppendUvarint(nil, 1), address...), huge...)},
+		{"huge far address count", append(append(append([]byte{}, router...), network...), huge...)},
+		{"truncated router", append(binary.AppendUvarint(nil, 2), address...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append(snapshotHeaderBytes(), tt.body...)
+			if _, _, err := Load(bytes.NewReader(input)); err == nil {
+				t.Fatal("expected an error")
+			}
+			// Without a known size, the counts are only found wrong once the
+			// input runs out.
+			if _, _, err := Load(io.MultiReader(bytes.NewReader(input))); err == nil {
+				t.Fatal("expected an error from a reader of unknown size")
+			}
+		})
+	}
+
+	_, _, err := Load(bytes.NewReader(append(snapshotHeaderBytes(), huge...)))
+	if !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("expected ErrCorruptSnapshot, got %v", err)
+	}
+}
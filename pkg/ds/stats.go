@@ -0,0 +1,169 @@
+package ds
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// FanOutHistogram counts the prefixes of a router by their number of next
+// hops, the i'th element is the number of prefixes with i next hops.
+type FanOutHistogram []int
+
+// Adds a prefix with the given number of next hops.
+func (h *FanOutHistogram) Add(fanOut int) {
+	for len(*h) <= fanOut {
+		*h = append(*h, 0)
+	}
+	(*h)[fanOut]++
+}
+
+// Formats the histogram as space separated fan-out:count pairs.
+func (h FanOutHistogram) String() string {
+	var sb strings.Builder
+	for fanOut, count := range h {
+		if count == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.Itoa(fanOut))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(count))
+	}
+	return sb.String()
+}
+
+// Marshals the histogram as an object of fan-out to count.
+func (h FanOutHistogram) MarshalJSON() ([]byte, error) {
+	m := make(map[string]int, len(h))
+	for fanOut, count := range h {
+		if count > 0 {
+			m[strconv.Itoa(fanOut)] = count
+		}
+	}
+	return json.Marshal(m)
+}
+
+// RouterStats holds the statistics of the forwarding table of a router.
+type RouterStats struct {
+	Address     netip.Addr
+	NumPrefixes int
+	// Number of distinct next hops over all the prefixes.
+	NumNextHops int
+	// Number of addresses covered by the union of the prefixes, overlapping
+	// prefixes are only counted once. The IPv4-mapped addresses are IPv4
+	// ones, so the IPv6 prefixes covering ::ffff:0:0/96 count them in the
+	// IPv4 addresses only, as the lookups route every IPv4 address over them.
+	IPv4Addresses uint64
+	IPv6Addresses *big.Int
+	FanOut        FanOutHistogram
+	// Number of prefixes with more than one next hop.
+	NumMultiPath int
+}
+
+// Returns the fraction of the prefixes with more than one next hop.
+func (s *RouterStats) MultiPathFraction() float64 {
+	if s.NumPrefixes == 0 {
+		return 0
+	}
+	return float64(s.NumMultiPath) / float64(s.NumPrefixes)
+}
+
+// Returns the average number of next hops per prefix.
+func (s *RouterStats) MeanFanOut() float64 {
+	if s.NumPrefixes == 0 {
+		return 0
+	}
+	total := 0
+	for fanOut, count := range s.FanOut {
+		total += fanOut * count
+	}
+	return float64(total) / float64(s.NumPrefixes)
+}
+
+// Returns the largest number of next hops of a prefix.
+func (s *RouterStats) MaxFanOut() int {
+	return len(s.FanOut) - 1
+}
+
+// Computes the statistics of the forwarding table.
+func (f *FT) Stats() RouterStats {
+	stats := RouterStats{
+		NumPrefixes:   f.Len(),
+		IPv6Addresses: new(big.Int),
+	}
+	nextHops := make(map[netip.Addr]struct{})
+
+	// The walk visits a prefix before the prefixes it covers, so a prefix is
+	// covered by a counted one only if the last counted prefix of its family
+	// covers it.
+	var counted4, counted6 netip.Prefix
+	coversIPv4 := false
+	size := new(big.Int)
+	f.Walk(func(prefix netip.Prefix, entry *FTEntry) bool {
+		for _, addr := range entry.Addrs() {
+			nextHops[addr] = struct{}{}
+		}
+		stats.FanOut.Add(entry.Len())
+		if entry.Len() > 1 {
+			stats.NumMultiPath++
+		}
+
+		counted := &counted6
+		if prefix.Addr().Is4() {
+			counted = &counted4
+		}
+		if counted.IsValid() && counted.Overlaps(prefix) {
+			return true
+		}
+		*counted = prefix
+
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		switch {
+		case prefix.Addr().Is4():
+			stats.IPv4Addresses += 1 << hostBits
+		case prefix.Overlaps(ipv4MappedPrefix):
+			coversIPv4 = true
+			size.Lsh(big.NewInt(1), uint(hostBits))
+			stats.IPv6Addresses.Add(stats.IPv6Addresses, size.Sub(size, big.NewInt(1<<32)))
+		default:
+			stats.IPv6Addresses.Add(stats.IPv6Addresses, size.Lsh(big.NewInt(1), uint(hostBits)))
+		}
+		return true
+	})
+	if coversIPv4 {
+		stats.IPv4Addresses = 1 << 32
+	}
+
+	stats.NumNextHops = len(nextHops)
+	return stats
+}
+
+// The IPv4-mapped addresses.
+var ipv4MappedPrefix = netip.PrefixFrom(netip.AddrFrom16([16]byte{10: 0xff, 11: 0xff}), 96)
+
+// Writes the statistics of every router.
+func (f *FIB) WriteStats(w io.Writer, opts ExportOptions) error {
+	t, err := NewTableWriter(w, opts.Format,
+		"address", "num_prefixes", "num_next_hops", "num_ipv4_addresses", "num_ipv6_addresses",
+		"max_fan_out", "mean_fan_out", "multipath_fraction", "fan_out")
+	if err != nil {
+		return err
+	}
+
+	f.WalkOrdered(opts.Sorted, func(nearAddress netip.Addr, ft *FT) bool {
+		s := ft.Stats()
+		err = t.Write(nearAddress, s.NumPrefixes, s.NumNextHops, s.IPv4Addresses, s.IPv6Addresses.String(),
+			s.MaxFanOut(), s.MeanFanOut(), s.MultiPathFraction(), s.FanOut)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return t.Flush()
+}
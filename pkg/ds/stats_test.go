@@ -0,0 +1,70 @@
+package ds
+
+import (
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestStatsAddresses(t *testing.T) {
+	pow := func(bits uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), bits) }
+	sub := func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) }
+	add := func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) }
+
+	tests := []struct {
+		name     string
+		prefixes []string
+		ipv4     uint64
+		ipv6     *big.Int
+	}{
+		{"empty", nil, 0, big.NewInt(0)},
+		{"ipv4 nested", []string{"8.8.0.0/16", "8.8.8.0/24", "9.9.9.0/24"}, 1<<16 + 1<<8, big.NewInt(0)},
+		{"ipv4 default route", []string{"0.0.0.0/0", "8.8.8.0/24"}, 1 << 32, big.NewInt(0)},
+		{"mapped prefix", []string{"::ffff:8.8.8.0/120"}, 1 << 8, big.NewInt(0)},
+		{"ipv6 only", []string{"2001:db8::/32", "2001:db8:1::/48", "2a00::/16"}, 0, add(pow(96), pow(112))},
+		{"both families", []string{"10.0.0.0/8", "2001:db8::/32"}, 1 << 24, pow(96)},
+		{"ipv6 default route", []string{"::/0", "8.8.8.0/24", "2001:db8::/32"}, 1 << 32, sub(pow(128), pow(32))},
+		{"ipv6 default route alone", []string{"::/0"}, 1 << 32, sub(pow(128), pow(32))},
+		{"ipv6 prefix covering the mapped addresses", []string{"::/64", "10.0.0.0/8"}, 1 << 32, sub(pow(64), pow(32))},
+		{"ipv6 prefix next to the mapped addresses", []string{"::/97"}, 0, pow(31)},
+	}
+	for _, tt := range tests {
+		for _, optimizeForIPv4 := range []bool{false, true} {
+			ft := NewFowardingTable(optimizeForIPv4, 24, 48)
+			for _, prefix := range tt.prefixes {
+				ft.InsertPrefix(netip.MustParsePrefix(prefix), netip.MustParseAddr("10.0.0.1"))
+			}
+			stats := ft.Stats()
+			if stats.IPv4Addresses != tt.ipv4 || stats.IPv6Addresses.Cmp(tt.ipv6) != 0 {
+				t.Errorf("%v, optimize=%v: got %v IPv4 and %v IPv6 addresses, want %v and %v",
+					tt.name, optimizeForIPv4, stats.IPv4Addresses, stats.IPv6Addresses, tt.ipv4, tt.ipv6)
+			}
+		}
+	}
+}
+
+func TestStatsFanOut(t *testing.T) {
+	f := testFIB(
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.1", "8.8.9.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "2001:db8::/48", "10.0.0.4"},
+		[3]string{"10.0.0.1", "2001:db9::/48", "10.0.0.4"},
+		[3]string{"10.0.0.1", "2001:db9::/48", "10.0.0.5"},
+		[3]string{"10.0.0.1", "2001:db9::/48", "10.0.0.6"},
+		[3]string{"2001:db8::1", "2a00::/16", "2001:db8::2"},
+	)
+
+	var sb strings.Builder
+	if err := f.WriteStats(&sb, ExportOptions{Format: ExportCSV, Sorted: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := `"address","num_prefixes","num_next_hops","num_ipv4_addresses","num_ipv6_addresses","max_fan_out","mean_fan_out","multipath_fraction","fan_out"
+"10.0.0.1","4","5","512","2417851639229258349412352","3","1.75","0.5","1:2 2:1 3:1"
+"2001:db8::1","1","1","0","5192296858534827628530496329220096","1","1","0","1:1"
+`
+	if sb.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", sb.String(), want)
+	}
+}
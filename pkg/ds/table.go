@@ -0,0 +1,187 @@
+package ds
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the format of the rows written by a TableWriter.
+type ExportFormat int
+
+const (
+	// ExportCSV writes every value quoted, with a header.
+	ExportCSV ExportFormat = iota
+	// ExportTSV writes tab separated values with a header, as ClickHouse
+	// TabSeparatedWithNames.
+	ExportTSV
+	// ExportJSONLines writes one JSON object per row.
+	ExportJSONLines
+)
+
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// Parses the name of an export format.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return ExportCSV, nil
+	case "tsv", "tabseparatedwithnames":
+		return ExportTSV, nil
+	case "json", "jsonl", "jsonlines", "jsoneachrow", "ndjson":
+		return ExportJSONLines, nil
+	default:
+		return ExportCSV, fmt.Errorf("%w: %v", ErrUnknownExportFormat, name)
+	}
+}
+
+// TableWriter writes rows of named columns one by one, nothing is kept in
+// memory besides the write buffer. Values are formatted with fmt, except for
+// JSON Lines where they are marshalled so numbers stay numbers.
+type TableWriter struct {
+	w       *bufio.Writer
+	format  ExportFormat
+	columns []string
+	keys    [][]byte
+	buf     []byte
+}
+
+// Creates a new table writer and writes the header if the format has one.
+func NewTableWriter(w io.Writer, format ExportFormat, columns ...string) (*TableWriter, error) {
+	t := &TableWriter{
+		w:       bufio.NewWriterSize(w, 64*1024),
+		format:  format,
+		columns: columns,
+	}
+
+	switch format {
+	case ExportJSONLines:
+		for _, column := range columns {
+			key, err := json.Marshal(column)
+			if err != nil {
+				return nil, err
+			}
+			t.keys = append(t.keys, append(key, ':'))
+		}
+		return t, nil
+	case ExportCSV, ExportTSV:
+		values := make([]any, len(columns))
+		for i, column := range columns {
+			values[i] = column
+		}
+		return t, t.Write(values...)
+	default:
+		return nil, ErrUnknownExportFormat
+	}
+}
+
+// Writes a row, there must be a value for every column.
+func (t *TableWriter) Write(values ...any) error {
+	if len(values) != len(t.columns) {
+		return fmt.Errorf("expected %v values, got %v", len(t.columns), len(values))
+	}
+
+	t.buf = t.buf[:0]
+	switch t.format {
+	case ExportJSONLines:
+		t.buf = append(t.buf, '{')
+		for i, value := range values {
+			if i > 0 {
+				t.buf = append(t.buf, ',')
+			}
+			t.buf = append(t.buf, t.keys[i]...)
+			encoded, err := json.Marshal(jsonValue(value))
+			if err != nil {
+				return err
+			}
+			t.buf = append(t.buf, encoded...)
+		}
+		t.buf = append(t.buf, '}')
+	case ExportTSV:
+		for i, value := range values {
+			if i > 0 {
+				t.buf = append(t.buf, '\t')
+			}
+			t.buf = appendTSV(t.buf, formatValue(value))
+		}
+	default:
+		for i, value := range values {
+			if i > 0 {
+				t.buf = append(t.buf, ',')
+			}
+			t.buf = append(t.buf, '"')
+			t.buf = append(t.buf, strings.ReplaceAll(formatValue(value), `"`, `""`)...)
+			t.buf = append(t.buf, '"')
+		}
+	}
+	t.buf = append(t.buf, '\n')
+
+	_, err := t.w.Write(t.buf)
+	return err
+}
+
+// Flushes the buffered rows into the underlying writer.
+func (t *TableWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// Formats a value for the text formats, nil values and zero times are written
+// empty.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case float64:
+		return fmt.Sprintf("%.6g", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Converts a value for JSON, zero times are written as null.
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v.UTC().Format(time.RFC3339)
+	case json.Marshaler, encoding.TextMarshaler:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// Appends the value escaped as ClickHouse escapes the TabSeparated values.
+func appendTSV(buf []byte, value string) []byte {
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,109 @@
+package ds
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes the test rows in the format.
+func writeTestTable(t *testing.T, format ExportFormat) string {
+	t.Helper()
+	nextHops := NewFTEntry()
+	nextHops.AddAddr(netip.MustParseAddr("10.0.0.3"))
+	nextHops.AddAddr(netip.MustParseAddr("10.0.0.2"))
+
+	var sb strings.Builder
+	w, err := NewTableWriter(&sb, format, "name", "addr", "prefix", "next_hops", "count", "ratio", "seen", "flag", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]any{
+		{"tab\there \"quoted\"\nnew line\r\\ back", netip.MustParseAddr("10.0.0.1"), netip.MustParsePrefix("8.8.8.0/24"),
+			NewFTEntry(), uint64(3), 0.5, time.Unix(1700000000, 0), true, nil},
+		{"", netip.MustParseAddr("2001:db8::1"), netip.MustParsePrefix("2001:db8::/48"),
+			nextHops, 0, 1.0 / 3, time.Time{}, false, nil},
+	}
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestTableWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		format ExportFormat
+		want   string
+	}{
+		{"csv", ExportCSV, `"name","addr","prefix","next_hops","count","ratio","seen","flag","missing"
+"tab	here ""quoted""
+new line` + "\r" + `\ back","10.0.0.1","8.8.8.0/24","{}","3","0.5","2023-11-14T22:13:20Z","true",""
+"","2001:db8::1","2001:db8::/48","{10.0.0.2, 10.0.0.3}","0","0.333333","","false",""
+`},
+		{"tsv", ExportTSV, "name\taddr\tprefix\tnext_hops\tcount\tratio\tseen\tflag\tmissing\n" +
+			`tab\there "quoted"\nnew line\r\\ back` + "\t10.0.0.1\t8.8.8.0/24\t{}\t3\t0.5\t2023-11-14T22:13:20Z\ttrue\t\n" +
+			"\t2001:db8::1\t2001:db8::/48\t{10.0.0.2, 10.0.0.3}\t0\t0.333333\t\tfalse\t\n"},
+		{"json lines", ExportJSONLines, `{"name":"tab\there \"quoted\"\nnew line\r\\ back","addr":"10.0.0.1","prefix":"8.8.8.0/24","next_hops":"{}","count":3,"ratio":0.5,"seen":"2023-11-14T22:13:20Z","flag":true,"missing":null}
+{"name":"","addr":"2001:db8::1","prefix":"2001:db8::/48","next_hops":"{10.0.0.2, 10.0.0.3}","count":0,"ratio":0.3333333333333333,"seen":null,"flag":false,"missing":null}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeTestTable(t, tt.format); got != tt.want {
+				t.Errorf("got\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableWriterErrors(t *testing.T) {
+	var sb strings.Builder
+	if _, err := NewTableWriter(&sb, ExportFormat(-1), "a"); !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownExportFormat)
+	}
+	w, err := NewTableWriter(&sb, ExportCSV, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write("only one"); err == nil {
+		t.Error("a row with a missing value was written")
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	f := testFIB(
+		[3]string{"10.0.0.2", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.3"},
+		[3]string{"10.0.0.1", "8.8.8.0/24", "10.0.0.2"},
+		[3]string{"10.0.0.1", "2001:db8::/48", "10.0.0.4"},
+	)
+	// A prefix with an empty set of next hops.
+	f.table(netip.MustParseAddr("10.0.0.1")).insert(netip.MustParsePrefix("9.9.9.0/24"), NewFTEntry())
+
+	var sb strings.Builder
+	if err := f.WriteText(&sb, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "10.0.0.1:\n" +
+		"\t8.8.8.0/24 -> {10.0.0.2, 10.0.0.3}\n" +
+		"\t9.9.9.0/24 -> {}\n" +
+		"\t2001:db8::/48 -> {10.0.0.4}\n" +
+		"10.0.0.2:\n" +
+		"\t8.8.8.0/24 -> {10.0.0.3}\n"
+	if sb.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", sb.String(), want)
+	}
+
+	// The export has no row for the prefix without next hops.
+	if export := exportString(t, f); strings.Contains(export, "9.9.9.0/24") {
+		t.Errorf("the export has the prefix without next hops\n%v", export)
+	}
+}
@@ -0,0 +1,365 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+)
+
+// key128 is the fixed-width key of the trie. Every address is stored as a 128
+// bit IPv6 address, IPv4 addresses are stored IPv4-mapped (::ffff:X.X.X.X) so
+// a single trie can hold both families.
+type key128 struct {
+	hi, lo uint64
+}
+
+// Converts the address into a key, IPv4 addresses are mapped into IPv6.
+func keyFromAddr(address netip.Addr) key128 {
+	b := address.As16()
+	return key128{
+		hi: binary.BigEndian.Uint64(b[:8]),
+		lo: binary.BigEndian.Uint64(b[8:]),
+	}
+}
+
+// Converts the prefix into a key and its length in the 128 bit key space.
+func keyFromPrefix(prefix netip.Prefix) (key128, int) {
+	length := prefix.Bits()
+	if prefix.Addr().Is4() {
+		length += 96
+	}
+	return keyFromAddr(prefix.Addr()).mask(length), length
+}
+
+// Converts the key back into an address. IPv4-mapped keys are unmapped.
+func (k key128) addr() netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], k.hi)
+	binary.BigEndian.PutUint64(b[8:], k.lo)
+	return netip.AddrFrom16(b).Unmap()
+}
+
+// Converts the key with the given length back into a prefix. Keys under the
+// IPv4-mapped range are converted into IPv4 prefixes.
+func (k key128) prefix(length int) netip.Prefix {
+	address := k.addr()
+	if address.Is4() {
+		if length >= 96 {
+			return netip.PrefixFrom(address, length-96)
+		}
+		address = netip.AddrFrom16(address.As16())
+	}
+	return netip.PrefixFrom(address, length)
+}
+
+// Returns the i'th bit of the key starting from the most significant one.
+func (k key128) bit(i int) int {
+	if i < 64 {
+		return int(k.hi>>(63-i)) & 1
+	}
+	return int(k.lo>>(127-i)) & 1
+}
+
+// Keeps the first length bits of the key and clears the rest.
+func (k key128) mask(length int) key128 {
+	switch {
+	case length <= 0:
+		return key128{}
+	case length < 64:
+		return key128{hi: k.hi &^ (^uint64(0) >> length)}
+	case length < 128:
+		return key128{hi: k.hi, lo: k.lo &^ (^uint64(0) >> (length - 64))}
+	default:
+		return k
+	}
+}
+
+// Returns the number of leading bits the two keys share.
+func (k key128) commonPrefixLength(o key128) int {
+	if x := k.hi ^ o.hi; x != 0 {
+		return bits.LeadingZeros64(x)
+	}
+	return 64 + bits.LeadingZeros64(k.lo^o.lo)
+}
+
+// Returns the number of bits of the key.
+func (k key128) width() int {
+	return 128
+}
+
+func (k key128) fromAddr(address netip.Addr) key128 {
+	return keyFromAddr(address)
+}
+
+func (k key128) fromPrefix(prefix netip.Prefix) (key128, int) {
+	return keyFromPrefix(prefix)
+}
+
+// key32 is the key of the IPv4 only tries, it is a quarter of key128 and
+// skips the ::ffff: part every IPv4 key has in the 128 bit key space.
+type key32 uint32
+
+// Returns the number of bits of the key.
+func (k key32) width() int {
+	return 32
+}
+
+// Converts the IPv4 address into a key, the address must be IPv4.
+func (k key32) fromAddr(address netip.Addr) key32 {
+	return key32(binary.BigEndian.Uint32(address.Unmap().AsSlice()))
+}
+
+// Converts the IPv4 prefix into a key and its length.
+func (k key32) fromPrefix(prefix netip.Prefix) (key32, int) {
+	return k.fromAddr(prefix.Addr()).mask(prefix.Bits()), prefix.Bits()
+}
+
+// Converts the key with the given length back into an IPv4 prefix.
+func (k key32) prefix(length int) netip.Prefix {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(k))
+	return netip.PrefixFrom(netip.AddrFrom4(b), length)
+}
+
+// Returns the i'th bit of the key starting from the most significant one.
+func (k key32) bit(i int) int {
+	return int(k>>(31-i)) & 1
+}
+
+// Keeps the first length bits of the key and clears the rest.
+func (k key32) mask(length int) key32 {
+	switch {
+	case length <= 0:
+		return 0
+	case length < 32:
+		return k &^ (^key32(0) >> length)
+	default:
+		return k
+	}
+}
+
+// Returns the number of leading bits the two keys share.
+func (k key32) commonPrefixLength(o key32) int {
+	return bits.LeadingZeros32(uint32(k ^ o))
+}
+
+// trieKey is the fixed-width key of a trie. The conversion methods do not
+// use the receiver, they are called on the zero key.
+type trieKey[K any] interface {
+	comparable
+	width() int
+	fromAddr(address netip.Addr) K
+	fromPrefix(prefix netip.Prefix) (K, int)
+	prefix(length int) netip.Prefix
+	bit(i int) int
+	mask(length int) K
+	commonPrefixLength(o K) int
+}
+
+// trieNode is a node of the path compressed binary trie. Nodes without a value
+// only exist to branch.
+type trieNode[K trieKey[K], V any] struct {
+	key      K
+	length   uint8
+	hasValue bool
+	value    V
+	children [2]*trieNode[K, V]
+}
+
+// keyedTrie is a path compressed binary trie (a PATRICIA trie) keyed by
+// prefixes, generic over the width of its keys.
+type keyedTrie[K trieKey[K], V any] struct {
+	root *trieNode[K, V]
+	size int
+}
+
+// Trie is a path compressed binary trie (a PATRICIA trie) keyed by prefixes. It
+// supports exact matches, longest prefix matches and ordered walks. IPv4 and
+// IPv6 prefixes can be mixed in the same trie.
+type Trie[V any] struct {
+	keyedTrie[key128, V]
+}
+
+// Creates a new empty trie.
+func NewTrie[V any]() *Trie[V] {
+	return &Trie[V]{}
+}
+
+// trie4 is a trie of IPv4 prefixes only, with 32 bit keys. Its nodes are
+// smaller and its lookups shorter than the ones of Trie. IPv6 prefixes must
+// not be given to it.
+type trie4[V any] struct {
+	keyedTrie[key32, V]
+}
+
+// Returns the number of prefixes stored in the trie.
+func (t *keyedTrie[K, V]) Len() int {
+	return t.size
+}
+
+// Inserts or replaces the value of the given prefix.
+func (t *keyedTrie[K, V]) Insert(prefix netip.Prefix, value V) {
+	var k K
+	key, length := k.fromPrefix(prefix)
+	t.insert(key, length, value)
+}
+
+func (t *keyedTrie[K, V]) insert(key K, length int, value V) {
+	np := &t.root
+	for {
+		n := *np
+		if n == nil {
+			*np = &trieNode[K, V]{key: key, length: uint8(length), hasValue: true, value: value}
+			t.size++
+			return
+		}
+
+		common := min(key.commonPrefixLength(n.key), length, int(n.length))
+		if common == int(n.length) {
+			if length == int(n.length) {
+				if !n.hasValue {
+					t.size++
+				}
+				n.value = value
+				n.hasValue = true
+				return
+			}
+			np = &n.children[key.bit(common)]
+			continue
+		}
+
+		if common == length {
+			// The new prefix covers the node, it becomes its parent.
+			parent := &trieNode[K, V]{key: key, length: uint8(length), hasValue: true, value: value}
+			parent.children[n.key.bit(common)] = n
+			*np = parent
+			t.size++
+			return
+		}
+
+		// The prefixes diverge, add a branching node above both.
+		branch := &trieNode[K, V]{key: key.mask(common), length: uint8(common)}
+		branch.children[n.key.bit(common)] = n
+		branch.children[key.bit(common)] = &trieNode[K, V]{key: key, length: uint8(length), hasValue: true, value: value}
+		*np = branch
+		t.size++
+		return
+	}
+}
+
+// Returns the value of the exact prefix.
+func (t *keyedTrie[K, V]) Get(prefix netip.Prefix) (V, bool) {
+	var k K
+	key, length := k.fromPrefix(prefix)
+	n := t.root
+	for n != nil && int(n.length) <= length && key.mask(int(n.length)) == n.key {
+		if int(n.length) == length {
+			return n.value, n.hasValue
+		}
+		n = n.children[key.bit(int(n.length))]
+	}
+
+	var zero V
+	return zero, false
+}
+
+// Performs a longest prefix match on the address and returns the matched
+// prefix and its value.
+func (t *keyedTrie[K, V]) LongestPrefix(address netip.Addr) (netip.Prefix, V, bool) {
+	var k K
+	key := k.fromAddr(address)
+	var best *trieNode[K, V]
+
+	n := t.root
+	for n != nil && key.mask(int(n.length)) == n.key {
+		if n.hasValue {
+			best = n
+		}
+		if int(n.length) == key.width() {
+			break
+		}
+		n = n.children[key.bit(int(n.length))]
+	}
+
+	if best == nil {
+		var zero V
+		return netip.Prefix{}, zero, false
+	}
+	return best.key.prefix(int(best.length)), best.value, true
+}
+
+// Removes the prefix from the trie, returns false if it was not there.
+func (t *keyedTrie[K, V]) Delete(prefix netip.Prefix) bool {
+	var k K
+	key, length := k.fromPrefix(prefix)
+	var deleted bool
+	t.root = t.root.delete(key, length, &deleted)
+	if deleted {
+		t.size--
+	}
+	return deleted
+}
+
+// Removes the key from the subtree and returns the node that replaces n.
+func (n *trieNode[K, V]) delete(key K, length int, deleted *bool) *trieNode[K, V] {
+	if n == nil || int(n.length) > length || key.mask(int(n.length)) != n.key {
+		return n
+	}
+
+	if int(n.length) < length {
+		i := key.bit(int(n.length))
+		n.children[i] = n.children[i].delete(key, length, deleted)
+	} else if n.hasValue {
+		var zero V
+		n.value = zero
+		n.hasValue = false
+		*deleted = true
+	}
+
+	if n.hasValue {
+		return n
+	}
+	// Nodes without a value are only kept if they still branch.
+	switch {
+	case n.children[0] == nil:
+		return n.children[1]
+	case n.children[1] == nil:
+		return n.children[0]
+	default:
+		return n
+	}
+}
+
+// Walks every prefix of the trie in order, shorter prefixes come before the
+// longer ones they cover. Returning false from fn stops the walk.
+func (t *keyedTrie[K, V]) Walk(fn func(prefix netip.Prefix, value V) bool) {
+	t.root.walk(fn)
+}
+
+// Walks every prefix covered by the given prefix, including itself, in order.
+// Returning false from fn stops the walk.
+func (t *keyedTrie[K, V]) WalkPrefix(prefix netip.Prefix, fn func(prefix netip.Prefix, value V) bool) {
+	var k K
+	key, length := k.fromPrefix(prefix)
+	n := t.root
+	for n != nil && int(n.length) < length {
+		if key.mask(int(n.length)) != n.key {
+			return
+		}
+		n = n.children[key.bit(int(n.length))]
+	}
+	if n == nil || n.key.mask(length) != key {
+		return
+	}
+	n.walk(fn)
+}
+
+func (n *trieNode[K, V]) walk(fn func(prefix netip.Prefix, value V) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.hasValue && !fn(n.key.prefix(int(n.length)), n.value) {
+		return false
+	}
+	return n.children[0].walk(fn) && n.children[1].walk(fn)
+}
@@ -0,0 +1,142 @@
+package ds
+
+import (
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/armon/go-radix"
+)
+
+// Returns n random prefixes of the given lengths, IPv6 ones with the given
+// probability.
+func randomPrefixes(r *rand.Rand, n int, ipv6Fraction float64) []netip.Prefix {
+	prefixes := make([]netip.Prefix, n)
+	for i := range prefixes {
+		if r.Float64() < ipv6Fraction {
+			var b [16]byte
+			b[0], b[1] = 0x20, 0x01
+			r.Read(b[2:8])
+			prefixes[i] = netip.PrefixFrom(netip.AddrFrom16(b), 32+r.Intn(33)).Masked()
+		} else {
+			var b [4]byte
+			r.Read(b[:])
+			prefixes[i] = netip.PrefixFrom(netip.AddrFrom4(b), 8+r.Intn(25)).Masked()
+		}
+	}
+	return prefixes
+}
+
+// Returns the address of a random host of a random prefix.
+func randomAddrs(r *rand.Rand, prefixes []netip.Prefix, n int) []netip.Addr {
+	addrs := make([]netip.Addr, n)
+	for i := range addrs {
+		prefix := prefixes[r.Intn(len(prefixes))]
+		b := prefix.Addr().AsSlice()
+		for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+			b[bit/8] |= byte(r.Intn(2)) << (7 - bit%8)
+		}
+		addrs[i], _ = netip.AddrFromSlice(b)
+	}
+	return addrs
+}
+
+// Returns the radix key of the prefix, its bits in the 128 bit key space as
+// a string of 0 and 1 so the longest prefix match of go-radix works on it.
+func radixKey(prefix netip.Prefix) string {
+	key, length := keyFromPrefix(prefix)
+	var sb strings.Builder
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		sb.WriteByte('0' + byte(key.bit(i)))
+	}
+	return sb.String()
+}
+
+func radixAddrKey(address netip.Addr) string {
+	return radixKey(netip.PrefixFrom(address, address.BitLen()))
+}
+
+func TestTrieLongestPrefixMatchesRadix(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	prefixes := randomPrefixes(r, 5000, 0.3)
+
+	trie := NewTrie[netip.Prefix]()
+	tree := radix.New()
+	for _, prefix := range prefixes {
+		trie.Insert(prefix, prefix)
+		tree.Insert(radixKey(prefix), prefix)
+	}
+	if trie.Len() != tree.Len() {
+		t.Fatalf("trie has %v prefixes, radix tree %v", trie.Len(), tree.Len())
+	}
+
+	for _, address := range randomAddrs(r, prefixes, 20000) {
+		prefix, value, found := trie.LongestPrefix(address)
+		_, expected, expectedFound := tree.LongestPrefix(radixAddrKey(address))
+		if found != expectedFound || (found && (value != expected || prefix != expected)) {
+			t.Fatalf("LongestPrefix(%v) = %v, %v, %v, radix tree gives %v, %v", address, prefix, value, found, expected, expectedFound)
+		}
+	}
+}
+
+func BenchmarkTrieInsert(b *testing.B) {
+	prefixes := randomPrefixes(rand.New(rand.NewSource(1)), 100_000, 0.2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie := NewTrie[int]()
+		for j, prefix := range prefixes {
+			trie.Insert(prefix, j)
+		}
+	}
+}
+
+func BenchmarkRadixInsert(b *testing.B) {
+	prefixes := randomPrefixes(rand.New(rand.NewSource(1)), 100_000, 0.2)
+	keys := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		keys[i] = radixKey(prefix)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := radix.New()
+		for j, key := range keys {
+			tree.Insert(key, j)
+		}
+	}
+}
+
+func BenchmarkTrieLongestPrefix(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	prefixes := randomPrefixes(r, 100_000, 0.2)
+	trie := NewTrie[int]()
+	for j, prefix := range prefixes {
+		trie.Insert(prefix, j)
+	}
+	addrs := randomAddrs(r, prefixes, 10_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.LongestPrefix(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkRadixLongestPrefix(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	prefixes := randomPrefixes(r, 100_000, 0.2)
+	tree := radix.New()
+	for j, prefix := range prefixes {
+		tree.Insert(radixKey(prefix), j)
+	}
+	// The addresses are converted into keys during the lookups, as a FIB
+	// on top of go-radix would do.
+	addrs := randomAddrs(r, prefixes, 10_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.LongestPrefix(radixAddrKey(addrs[i%len(addrs)]))
+	}
+}
@@ -1,55 +1,86 @@
 package ds
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
 	"net"
-	"strings"
+	"net/netip"
 )
 
 var ErrGivenAddressNil = errors.New("given address is nil")
 
-// Convert the IP into a binary string. It automatically maps it into IPv6
-// if it is a IPv4.
-func IPToKey(address *net.IP) (string, error) {
+// Converts the IP into a netip.Addr. IPv4 and IPv4-mapped IPv6 addresses are
+// both converted into IPv4 so they compare equal.
+func AddrFromIP(address *net.IP) (netip.Addr, error) {
 	if address == nil {
-		return "", ErrGivenAddressNil
+		return netip.Addr{}, ErrGivenAddressNil
 	}
-	ip := address.To16()
-	b := ""
-	for _, v := range ip {
-		b += fmt.Sprintf("%08b", v)
+	addr, ok := netip.AddrFromSlice(*address)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("invalid ip address %v", *address)
 	}
-	return b, nil
+	return addr.Unmap(), nil
 }
 
-// Convert the the given network into
-func NetworkToKey(network *net.IPNet) (string, error) {
-	if network == nil {
-		return "", ErrGivenAddressNil
+// Converts the network into a netip.Prefix. IPv4-mapped networks with a 128
+// bit mask, as created by IPToNetwork, are converted into IPv4 prefixes.
+func PrefixFromNetwork(network *net.IPNet) (netip.Prefix, error) {
+	if network == nil || network.IP == nil {
+		return netip.Prefix{}, ErrGivenAddressNil
 	}
-	if network.IP == nil {
-		return "", ErrGivenAddressNil
+	addr, ok := netip.AddrFromSlice(network.IP)
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("invalid network address %v", network.IP)
+	}
+	prefixLength, maskLength := network.Mask.Size()
+	if maskLength == 0 {
+		return netip.Prefix{}, fmt.Errorf("non canonical network mask %v", network.Mask)
 	}
-	// normalzie the prefix
-	prefix := network.IP.To16()
 
-	// convert the prefix to key
-	prefixKey, err := IPToKey(&prefix)
-	if err != nil {
-		return "", err
+	if addr.Is4In6() && maskLength == 128 && prefixLength >= 96 {
+		addr = addr.Unmap()
+		prefixLength -= 96
+	} else if addr.Is4() && maskLength == 128 {
+		// A 4 byte address with a 16 byte mask, treat the mask as IPv4-mapped.
+		prefixLength -= 96
+	} else if addr.Is4In6() && maskLength == 32 {
+		addr = addr.Unmap()
+	}
+	if prefixLength < 0 {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length for the network %v", network)
 	}
 
-	prefixLength, _ := network.Mask.Size()
-	extra := 0
+	return netip.PrefixFrom(addr, prefixLength).Masked(), nil
+}
 
-	// if IsMappedToIPv6(prefix) {
-	// 	extra += 96
-	// }
+// Converts the IPv4-mapped prefixes into IPv4 ones, the length of the mapped
+// prefix counts the 96 bits of the mapping. The others are returned as is.
+func UnmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix
+}
 
-	return prefixKey[:prefixLength+extra], nil
+// Returns the network of the address using the prefix length of its family,
+// IPv4-mapped addresses are treated as IPv4.
+func DefaultPrefix(address netip.Addr, ipv4PrefixLength uint, ipv6PrefixLength uint) (netip.Prefix, error) {
+	address = address.Unmap()
+	if address.Is4() {
+		return address.Prefix(int(ipv4PrefixLength))
+	}
+	return address.Prefix(int(ipv6PrefixLength))
+}
+
+// Converts the prefix into a net.IPNet in the format IPToNetwork creates,
+// IPv4 prefixes are IPv4-mapped with a 128 bit mask.
+func PrefixToNetwork(prefix netip.Prefix) *net.IPNet {
+	key, length := keyFromPrefix(prefix)
+	ip := key.addr().As16()
+	return &net.IPNet{
+		IP:   net.IP(ip[:]),
+		Mask: net.CIDRMask(length, 128),
+	}
 }
 
 // Checks if the IP is a pure IPv6 or an IPv4-mapped IPv6
@@ -100,53 +131,3 @@ func IPToNetwork(address *net.IP, prefixLength int) (*net.IPNet, error) {
 		}, nil
 	}
 }
-
-// Adds zeros to the end to fix it to 128 bits.
-func AddPaddingToKey(key string) (string, error) {
-	if len(key) > 128 {
-		return "", errors.New("given key is larger than 128 characters")
-	}
-	postfix := strings.Repeat("0", 128-len(key))
-
-	return fmt.Sprintf("%s%s", key, postfix), nil
-}
-
-// Convert the key into an IP address.
-func KeyToIP(key string) (*net.IP, error) {
-	// Add paddings to they key
-	key, err := AddPaddingToKey(key)
-	if err != nil {
-		return nil, err
-	}
-
-	n := new(big.Int)
-	n, ok := n.SetString(key, 2)
-	if !ok {
-		return nil, fmt.Errorf("invalid binary string")
-	}
-
-	// Convert to hex string
-	hexStr := n.Text(16)
-	if len(hexStr) < 32 {
-		hexStr = fmt.Sprintf("%032s", hexStr) // pad to 32 hex digits (128 bits)
-	}
-
-	// Convert hex string to bytes
-	bytes, err := hex.DecodeString(hexStr)
-	if err != nil {
-		return nil, err
-	}
-
-	r := net.IP(bytes).To16()
-	return &r, nil
-}
-
-// Convert the key with a certain prefix legnth to a network
-func KeyToNetwork(key string, prefixLength int) (*net.IPNet, error) {
-	ip, err := KeyToIP(key)
-	if err != nil {
-		return nil, err
-	}
-
-	return IPToNetwork(ip, prefixLength)
-}
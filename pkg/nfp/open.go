@@ -0,0 +1,259 @@
+package nfp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Compression is the compression of an input, it is detected by its magic
+// bytes.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionLZ4
+)
+
+var compressionMagics = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{CompressionGzip, []byte{0x1f, 0x8b}},
+	{CompressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{CompressionLZ4, []byte{0x04, 0x22, 0x4d, 0x18}},
+	// The legacy lz4 format, as written by lz4 -l.
+	{CompressionLZ4, []byte{0x02, 0x21, 0x4c, 0x18}},
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionLZ4:
+		return "lz4"
+	default:
+		return "none"
+	}
+}
+
+// Detects the compression of the reader and returns a reader of the
+// decompressed content. Closing it does not close the underlying reader.
+func Decompress(r io.Reader) (io.ReadCloser, Compression, error) {
+	buffered := bufio.NewReaderSize(r, 1024*1024)
+	head, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, CompressionNone, err
+	}
+
+	compression := CompressionNone
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(head, m.magic) {
+			compression = m.compression
+			break
+		}
+	}
+
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, compression, err
+		}
+		return gz, compression, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, compression, err
+		}
+		return zr.IOReadCloser(), compression, nil
+	case CompressionLZ4:
+		return io.NopCloser(lz4.NewReader(buffered)), compression, nil
+	default:
+		return io.NopCloser(buffered), compression, nil
+	}
+}
+
+// Opens the file and decompresses it if needed, - means stdin.
+func Open(path string) (io.ReadCloser, Compression, error) {
+	var file *os.File
+	if path == "-" {
+		file = os.Stdin
+	} else {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+	}
+
+	r, compression, err := Decompress(file)
+	if err != nil {
+		if file != os.Stdin {
+			file.Close()
+		}
+		return nil, compression, fmt.Errorf("%v: %w", path, err)
+	}
+	return &fileReadCloser{ReadCloser: r, file: file}, compression, nil
+}
+
+// fileReadCloser closes the decompressor and then the file.
+type fileReadCloser struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (f *fileReadCloser) Close() error {
+	err := f.ReadCloser.Close()
+	if f.file != os.Stdin {
+		if cerr := f.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Expands the glob patterns into the list of files. The matches of a pattern
+// are sorted, - is kept as is for stdin, and a pattern without any match is
+// an error.
+func ExpandPaths(patterns []string) ([]string, error) {
+	paths := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "-" {
+			paths = append(paths, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %v", pattern)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// MultiReader reads the records of several exports one after the other. Each
+// file is decompressed if needed and has its own header, so the files do not
+// need to have the same columns.
+type MultiReader struct {
+	paths   []string
+	format  Format
+	i       int
+	current *Reader
+	closer  io.Closer
+	report  *Report
+}
+
+// Creates a reader of the given files, see ExpandPaths.
+func NewMultiReader(paths []string, format Format) *MultiReader {
+	return &MultiReader{
+		paths:  paths,
+		format: format,
+		report: NewReport(),
+	}
+}
+
+// Returns the report of the lines read so far over all the files.
+func (m *MultiReader) Report() *Report {
+	return m.report
+}
+
+// Returns the reader of the current file, opening the next file if needed.
+func (m *MultiReader) reader() (*Reader, error) {
+	if m.current != nil {
+		return m.current, nil
+	}
+	if m.i >= len(m.paths) {
+		return nil, io.EOF
+	}
+
+	path := m.paths[m.i]
+	in, _, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	m.current = NewReader(in, m.format)
+	m.current.name = path
+	m.current.report = m.report
+	m.closer = in
+	return m.current, nil
+}
+
+// Closes the current file and moves to the next one.
+func (m *MultiReader) next() error {
+	err := m.closer.Close()
+	m.current = nil
+	m.closer = nil
+	m.i++
+	return err
+}
+
+// Reads the next record, it returns io.EOF once all the files are over.
+func (m *MultiReader) Read() (Record, error) {
+	for {
+		r, err := m.reader()
+		if err != nil {
+			return Record{}, err
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			if err := m.next(); err != nil {
+				return Record{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return Record{}, fmt.Errorf("%v: %w", r.name, err)
+		}
+		return record, nil
+	}
+}
+
+// Reads at most size lines of the current file without parsing them, a batch
+// never spans two files. It returns io.EOF once all the files are over.
+func (m *MultiReader) ReadBatch(size int) (*Batch, error) {
+	for {
+		r, err := m.reader()
+		if err != nil {
+			return nil, err
+		}
+		batch, err := r.ReadBatch(size)
+		if err == io.EOF {
+			if err := m.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", r.name, err)
+		}
+		return batch, nil
+	}
+}
+
+// Closes the file being read.
+func (m *MultiReader) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	err := m.closer.Close()
+	m.current = nil
+	m.closer = nil
+	return err
+}
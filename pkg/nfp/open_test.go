@@ -0,0 +1,84 @@
+package nfp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+func TestDecompress(t *testing.T) {
+	content := strings.Repeat("near_addr,far_addr,probe_dst_addr\n10.0.0.1,10.0.0.2,8.8.8.8\n", 1000)
+
+	tests := []struct {
+		name        string
+		compression Compression
+		compress    func(w io.Writer) io.WriteCloser
+	}{
+		{"none", CompressionNone, func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }},
+		{"gzip", CompressionGzip, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"zstd", CompressionZstd, func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return zw
+		}},
+		{"lz4", CompressionLZ4, func(w io.Writer) io.WriteCloser { return lz4.NewWriter(w) }},
+		{"lz4 legacy", CompressionLZ4, func(w io.Writer) io.WriteCloser {
+			lw := lz4.NewWriter(w)
+			if err := lw.Apply(lz4.LegacyOption(true)); err != nil {
+				t.Fatal(err)
+			}
+			return lw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := tt.compress(&buf)
+			if _, err := io.WriteString(w, content); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, compression, err := Decompress(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if compression != tt.compression {
+				t.Errorf("compression = %v, want %v", compression, tt.compression)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("decompressed %v bytes, want %v", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestDecompressShortInput(t *testing.T) {
+	r, compression, err := Decompress(strings.NewReader("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(r); compression != CompressionNone || string(got) != "a" {
+		t.Errorf("got %q with %v", got, compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
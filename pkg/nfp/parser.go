@@ -0,0 +1,390 @@
+package nfp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the format of an NFP export.
+type Format int
+
+const (
+	// FormatAuto detects the format from the first line.
+	FormatAuto Format = iota
+	// FormatCSV is comma separated values, as ClickHouse CSVWithNames.
+	FormatCSV
+	// FormatTSV is tab separated values with the ClickHouse escaping, as
+	// ClickHouse TabSeparatedWithNames.
+	FormatTSV
+	// FormatJSONEachRow is one JSON object per line, as ClickHouse JSONEachRow.
+	FormatJSONEachRow
+)
+
+var ErrUnknownFormat = errors.New("unknown NFP format")
+
+// Parses the name of a format, the ClickHouse names are accepted as well.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return FormatAuto, nil
+	case "csv", "csvwithnames":
+		return FormatCSV, nil
+	case "tsv", "tabseparated", "tabseparatedwithnames", "tsvwithnames":
+		return FormatTSV, nil
+	case "json", "jsoneachrow", "ndjson", "jsonl":
+		return FormatJSONEachRow, nil
+	default:
+		return FormatAuto, fmt.Errorf("%w: %v", ErrUnknownFormat, name)
+	}
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatCSV:
+		return "CSV"
+	case FormatTSV:
+		return "TabSeparatedWithNames"
+	case FormatJSONEachRow:
+		return "JSONEachRow"
+	default:
+		return "auto"
+	}
+}
+
+// Detects the format from the first line of the export.
+func DetectFormat(line []byte) Format {
+	line = bytes.TrimSpace(line)
+	switch {
+	case bytes.HasPrefix(line, []byte("{")):
+		return FormatJSONEachRow
+	case bytes.IndexByte(line, '\t') >= 0:
+		return FormatTSV
+	default:
+		return FormatCSV
+	}
+}
+
+// The columns of the export, the first name of each column is the canonical
+// one and the rest are accepted aliases.
+const (
+	columnNearAddr = iota
+	columnFarAddr
+	columnProbeDstAddr
+	columnRound
+	columnTTL
+	columnProbeProtocol
+	columnTimestamp
+	numColumns
+)
+
+var columnNames = [numColumns][]string{
+	columnNearAddr:      {"near_addr"},
+	columnFarAddr:       {"far_addr"},
+	columnProbeDstAddr:  {"probe_dst_addr"},
+	columnRound:         {"round", "near_round"},
+	columnTTL:           {"ttl", "near_ttl"},
+	columnProbeProtocol: {"probe_protocol"},
+	columnTimestamp:     {"timestamp", "capture_timestamp"},
+}
+
+// The columns a header must have.
+var requiredColumns = []int{columnNearAddr, columnFarAddr, columnProbeDstAddr}
+
+// Returns the column of the given name or -1.
+func columnByName(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for column, names := range columnNames {
+		for _, n := range names {
+			if n == name {
+				return column
+			}
+		}
+	}
+	return -1
+}
+
+// Parser parses the lines of an export once its header is known. It has no
+// state besides the column mapping, so it can be shared between goroutines.
+type Parser struct {
+	format Format
+	// Index of every column in the line, -1 if it is not in the export.
+	index [numColumns]int
+	// Number of fields of a line, zero for JSONEachRow.
+	numFields int
+}
+
+var ErrMissingColumn = errors.New("missing mandatory column")
+
+// Creates a parser for a delimited export from its header fields.
+func newParserFromHeader(format Format, header []string) (*Parser, error) {
+	p := &Parser{format: format, numFields: len(header)}
+	for i := range p.index {
+		p.index[i] = -1
+	}
+	for i, name := range header {
+		if column := columnByName(name); column >= 0 && p.index[column] < 0 {
+			p.index[column] = i
+		}
+	}
+	for _, column := range requiredColumns {
+		if p.index[column] < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrMissingColumn, columnNames[column][0])
+		}
+	}
+	return p, nil
+}
+
+// Creates a parser for exports without a header, the columns are expected in
+// the near_addr, far_addr, probe_dst_addr order.
+func newDefaultParser(format Format, numFields int) *Parser {
+	p := &Parser{format: format, numFields: numFields}
+	for i := range p.index {
+		p.index[i] = -1
+	}
+	for i, column := range requiredColumns {
+		p.index[column] = i
+	}
+	return p
+}
+
+// Returns the format of the parser.
+func (p *Parser) Format() Format {
+	return p.format
+}
+
+// Parses a single line into a record. The line number is only used for the
+// error.
+func (p *Parser) ParseLine(line []byte, lineNumber int) (Record, *ParseError) {
+	if p.format == FormatJSONEachRow {
+		return p.parseJSON(line, lineNumber)
+	}
+
+	var fields []string
+	var err error
+	if p.format == FormatTSV {
+		fields, err = splitTSV(line)
+	} else {
+		fields, err = splitCSV(line)
+	}
+	if err != nil {
+		return Record{}, &ParseError{Line: lineNumber, Err: err}
+	}
+	if len(fields) != p.numFields {
+		return Record{}, &ParseError{Line: lineNumber, Err: fmt.Errorf("expected %v columns, got %v", p.numFields, len(fields))}
+	}
+
+	return p.parseFields(lineNumber, func(column int) (string, bool) {
+		if p.index[column] < 0 {
+			return "", false
+		}
+		return fields[p.index[column]], true
+	})
+}
+
+// Parses a JSONEachRow line, the columns are looked up by name.
+func (p *Parser) parseJSON(line []byte, lineNumber int) (Record, *ParseError) {
+	object := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return Record{}, &ParseError{Line: lineNumber, Err: err}
+	}
+
+	values := [numColumns]any{}
+	present := [numColumns]bool{}
+	for name, value := range object {
+		if column := columnByName(name); column >= 0 && !present[column] {
+			values[column] = value
+			present[column] = true
+		}
+	}
+
+	return p.parseFields(lineNumber, func(column int) (string, bool) {
+		if !present[column] || values[column] == nil {
+			return "", false
+		}
+		switch v := values[column].(type) {
+		case string:
+			return v, true
+		case json.Number:
+			return v.String(), true
+		default:
+			return fmt.Sprint(v), true
+		}
+	})
+}
+
+// Builds the record from the raw values of the columns.
+func (p *Parser) parseFields(lineNumber int, value func(column int) (string, bool)) (Record, *ParseError) {
+	var record Record
+	var perr *ParseError
+	fail := func(column int, raw string, err error) {
+		if perr == nil {
+			perr = &ParseError{Line: lineNumber, Column: columnNames[column][0], Value: raw, Err: err}
+		}
+	}
+
+	addrs := [3]*netip.Addr{&record.NearAddr, &record.FarAddr, &record.ProbeDstAddr}
+	for i, column := range requiredColumns {
+		raw, ok := value(column)
+		if !ok {
+			fail(column, "", ErrMissingColumn)
+			continue
+		}
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			fail(column, raw, err)
+			continue
+		}
+		*addrs[i] = addr.Unmap()
+	}
+
+	if raw, ok := value(columnRound); ok && !isNull(raw) {
+		if v, err := strconv.ParseUint(raw, 10, 32); err != nil {
+			fail(columnRound, raw, err)
+		} else {
+			record.Round = uint32(v)
+			record.Fields |= FieldRound
+		}
+	}
+	if raw, ok := value(columnTTL); ok && !isNull(raw) {
+		if v, err := strconv.ParseUint(raw, 10, 8); err != nil {
+			fail(columnTTL, raw, err)
+		} else {
+			record.TTL = uint8(v)
+			record.Fields |= FieldTTL
+		}
+	}
+	if raw, ok := value(columnProbeProtocol); ok && !isNull(raw) {
+		if v, err := strconv.ParseUint(raw, 10, 8); err != nil {
+			fail(columnProbeProtocol, raw, err)
+		} else {
+			record.ProbeProtocol = uint8(v)
+			record.Fields |= FieldProbeProtocol
+		}
+	}
+	if raw, ok := value(columnTimestamp); ok && !isNull(raw) {
+		if v, err := parseTimestamp(raw); err != nil {
+			fail(columnTimestamp, raw, err)
+		} else {
+			record.Timestamp = v
+			record.Fields |= FieldTimestamp
+		}
+	}
+
+	if perr != nil {
+		return Record{}, perr
+	}
+	return record, nil
+}
+
+// ClickHouse writes NULL as \N in the text formats.
+func isNull(raw string) bool {
+	return raw == "" || raw == `\N`
+}
+
+// The layouts of the ClickHouse DateTime and DateTime64 types.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// Parses a timestamp, either a ClickHouse date time or a unix time in seconds
+// with an optional fraction.
+func parseTimestamp(raw string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return time.Time{}, errors.New("unknown timestamp format")
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+}
+
+// Splits a CSV line, the fields may be quoted with double quotes.
+func splitCSV(line []byte) ([]string, error) {
+	fields := make([]string, 0, 8)
+	var sb strings.Builder
+	for i := 0; ; {
+		sb.Reset()
+		if i < len(line) && line[i] == '"' {
+			i++
+			for {
+				if i >= len(line) {
+					return nil, errors.New("unterminated quoted field")
+				}
+				if line[i] == '"' {
+					if i+1 < len(line) && line[i+1] == '"' {
+						sb.WriteByte('"')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			if i < len(line) && line[i] != ',' {
+				return nil, errors.New("unexpected character after quoted field")
+			}
+		} else {
+			end := bytes.IndexByte(line[i:], ',')
+			if end < 0 {
+				end = len(line) - i
+			}
+			sb.Write(line[i : i+end])
+			i += end
+		}
+
+		fields = append(fields, sb.String())
+		if i >= len(line) {
+			return fields, nil
+		}
+		i++ // the comma
+	}
+}
+
+// Splits a TSV line and unescapes the fields as ClickHouse escapes them.
+func splitTSV(line []byte) ([]string, error) {
+	raw := bytes.Split(line, []byte{'\t'})
+	fields := make([]string, len(raw))
+	for i, field := range raw {
+		if bytes.IndexByte(field, '\\') < 0 || bytes.Equal(field, []byte(`\N`)) {
+			fields[i] = string(field)
+			continue
+		}
+		var sb strings.Builder
+		for j := 0; j < len(field); j++ {
+			if field[j] != '\\' || j+1 == len(field) {
+				sb.WriteByte(field[j])
+				continue
+			}
+			j++
+			switch field[j] {
+			case 't':
+				sb.WriteByte('\t')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case '0':
+				sb.WriteByte(0)
+			default:
+				sb.WriteByte(field[j])
+			}
+		}
+		fields[i] = sb.String()
+	}
+	return fields, nil
+}
@@ -0,0 +1,175 @@
+package nfp
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCSV(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: `a,b,c`, want: []string{"a", "b", "c"}},
+		{line: `a,,c`, want: []string{"a", "", "c"}},
+		{line: `a,b,`, want: []string{"a", "b", ""}},
+		{line: `"a","b,c",d`, want: []string{"a", "b,c", "d"}},
+		{line: `"say ""hi""",x`, want: []string{`say "hi"`, "x"}},
+		{line: `""`, want: []string{""}},
+		{line: `"a`, wantErr: true},
+		{line: `"a"b,c`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitCSV([]byte(tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitCSV(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("splitCSV(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitTSV(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "a\tb\tc", want: []string{"a", "b", "c"}},
+		{line: "a\t\tc", want: []string{"a", "", "c"}},
+		{line: `a\tb` + "\tc", want: []string{"a\tb", "c"}},
+		{line: `a\nb\rc\0d`, want: []string{"a\nb\rc\x00d"}},
+		{line: `a\\b\'c`, want: []string{`a\b'c`}},
+		{line: `\N` + "\tx", want: []string{`\N`, "x"}},
+		{line: `trailing\`, want: []string{`trailing\`}},
+	}
+	for _, tt := range tests {
+		got, err := splitTSV([]byte(tt.line))
+		if err != nil {
+			t.Errorf("splitTSV(%q) error = %v", tt.line, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitTSV(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestColumnByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"near_addr", columnNearAddr},
+		{" NEAR_ADDR ", columnNearAddr},
+		{"near_round", columnRound},
+		{"round", columnRound},
+		{"near_ttl", columnTTL},
+		{"capture_timestamp", columnTimestamp},
+		{"timestamp", columnTimestamp},
+		{"probe_src_addr", -1},
+	}
+	for _, tt := range tests {
+		if got := columnByName(tt.name); got != tt.want {
+			t.Errorf("columnByName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Reads every record of the export, the report is returned with them.
+func readAll(t *testing.T, input string, format Format) ([]Record, *Report) {
+	t.Helper()
+	r := NewReader(strings.NewReader(input), format)
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records, r.Report()
+}
+
+func TestReaderHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		fields Fields
+	}{
+		{
+			name:   "csv with aliases",
+			input:  "capture_timestamp,near_round,near_addr,far_addr,probe_dst_addr\n2024-01-01 10:00:00,2,10.0.0.1,10.0.0.2,8.8.8.8\n",
+			fields: FieldTimestamp | FieldRound,
+		},
+		{
+			name:   "tsv with nulls",
+			input:  "near_addr\tfar_addr\tprobe_dst_addr\tnear_ttl\tround\n10.0.0.1\t10.0.0.2\t8.8.8.8\t\\N\t2\n",
+			fields: FieldRound,
+		},
+		{
+			name:  "without header",
+			input: "10.0.0.1,10.0.0.2,8.8.8.8\n",
+		},
+		{
+			name:   "json with nulls",
+			input:  `{"near_addr":"10.0.0.1","far_addr":"::ffff:10.0.0.2","probe_dst_addr":"8.8.8.8","near_ttl":null,"round":2}` + "\n",
+			fields: FieldRound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, report := readAll(t, tt.input, FormatAuto)
+			if len(records) != 1 || report.Errors != 0 {
+				t.Fatalf("got %v records and %v errors, want 1 record: %v", len(records), report.Errors, report.Samples)
+			}
+			record := records[0]
+			if record.NearAddr != netip.MustParseAddr("10.0.0.1") || record.FarAddr != netip.MustParseAddr("10.0.0.2") ||
+				record.ProbeDstAddr != netip.MustParseAddr("8.8.8.8") {
+				t.Errorf("unexpected addresses %v %v %v", record.NearAddr, record.FarAddr, record.ProbeDstAddr)
+			}
+			if record.Fields != tt.fields {
+				t.Errorf("fields = %b, want %b", record.Fields, tt.fields)
+			}
+			if record.Has(FieldRound) && record.Round != 2 {
+				t.Errorf("round = %v, want 2", record.Round)
+			}
+			if record.Has(FieldTimestamp) && !record.Timestamp.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+				t.Errorf("timestamp = %v", record.Timestamp)
+			}
+		})
+	}
+}
+
+func TestReaderReport(t *testing.T) {
+	input := "near_addr,far_addr,probe_dst_addr,round\n" +
+		"10.0.0.1,10.0.0.2,8.8.8.8,1\n" +
+		"\n" +
+		"10.0.0.1,bad,8.8.8.8,1\n" +
+		"10.0.0.1,10.0.0.2,8.8.8.8,x\n" +
+		"10.0.0.1,10.0.0.2\n" +
+		"10.0.0.3,10.0.0.4,1.1.1.1,\n"
+	records, report := readAll(t, input, FormatCSV)
+
+	if len(records) != 2 || records[0].Line != 2 || records[1].Line != 7 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if report.Lines != 5 || report.Records != 2 || report.Errors != 3 {
+		t.Errorf("report = %v", report)
+	}
+	if report.ByColumn["far_addr"] != 1 || report.ByColumn["round"] != 1 || report.ByColumn["-"] != 1 {
+		t.Errorf("errors by column = %v", report.ByColumn)
+	}
+	lines := make([]int, len(report.Samples))
+	for i, sample := range report.Samples {
+		lines[i] = sample.Line
+	}
+	if !slices.Equal(lines, []int{4, 5, 6}) {
+		t.Errorf("sample lines = %v, want [4 5 6]", lines)
+	}
+}
@@ -0,0 +1,231 @@
+package nfp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Maximum length of a line in an export.
+const MaxLineLength = 1024 * 1024
+
+var ErrUnrecognizedHeader = errors.New("cannot recognize the header of the NFP export")
+
+// Reader reads the records of an NFP export. The first line is the header and
+// the columns are mapped by their names. Exports without a header are
+// accepted when their first line starts with an address, the columns are then
+// expected in the near_addr, far_addr, probe_dst_addr order.
+//
+// Lines that cannot be parsed are skipped and recorded in the report.
+type Reader struct {
+	scanner *bufio.Scanner
+	name    string
+	format  Format
+	parser  *Parser
+	// The first line of an export without a header, it is data.
+	pending     []byte
+	pendingLine int
+	line        int
+	report      *Report
+	err         error
+}
+
+// Creates a new reader of the given format.
+func NewReader(r io.Reader, format Format) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxLineLength)
+	return &Reader{
+		scanner: scanner,
+		format:  format,
+		report:  NewReport(),
+	}
+}
+
+// Returns the report of the lines read so far.
+func (r *Reader) Report() *Report {
+	return r.report
+}
+
+// Reads the header if it is not read yet and returns the parser of the export.
+func (r *Reader) Parser() (*Parser, error) {
+	if r.parser == nil && r.err == nil {
+		r.err = r.readHeader()
+	}
+	return r.parser, r.err
+}
+
+// Scans the next non empty line.
+func (r *Reader) scan() ([]byte, bool) {
+	for r.scanner.Scan() {
+		r.line++
+		if line := bytes.TrimRight(r.scanner.Bytes(), "\r"); len(line) > 0 {
+			return line, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Reader) readHeader() error {
+	line, ok := r.scan()
+	if !ok {
+		if err := r.scanner.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	format := r.format
+	if format == FormatAuto {
+		format = DetectFormat(line)
+	}
+
+	if format == FormatJSONEachRow {
+		r.parser = &Parser{format: format}
+		r.setPending(line)
+		return nil
+	}
+
+	var fields []string
+	var err error
+	if format == FormatTSV {
+		fields, err = splitTSV(line)
+	} else {
+		fields, err = splitCSV(line)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnrecognizedHeader, err)
+	}
+
+	for _, field := range fields {
+		if columnByName(field) >= 0 {
+			r.parser, err = newParserFromHeader(format, fields)
+			return err
+		}
+	}
+
+	if _, err := netip.ParseAddr(fields[0]); err == nil && len(fields) >= len(requiredColumns) {
+		r.parser = newDefaultParser(format, len(fields))
+		r.setPending(line)
+		return nil
+	}
+	return ErrUnrecognizedHeader
+}
+
+func (r *Reader) setPending(line []byte) {
+	r.pending = append([]byte(nil), line...)
+	r.pendingLine = r.line
+}
+
+// Returns the next data line and its number.
+func (r *Reader) nextLine() ([]byte, int, bool) {
+	if r.pending != nil {
+		line := r.pending
+		r.pending = nil
+		return line, r.pendingLine, true
+	}
+	line, ok := r.scan()
+	return line, r.line, ok
+}
+
+// Reads the next record, it returns io.EOF once the export is over.
+func (r *Reader) Read() (Record, error) {
+	parser, err := r.Parser()
+	if err != nil {
+		return Record{}, err
+	}
+
+	for {
+		line, lineNumber, ok := r.nextLine()
+		if !ok {
+			if err := r.scanner.Err(); err != nil {
+				r.err = err
+				return Record{}, err
+			}
+			return Record{}, io.EOF
+		}
+
+		r.report.Lines++
+		record, perr := parser.ParseLine(line, lineNumber)
+		if perr != nil {
+			perr.File = r.name
+			r.report.Add(perr)
+			continue
+		}
+		r.report.Records++
+		record.File, record.Line = r.name, lineNumber
+		return record, nil
+	}
+}
+
+// Batch is a group of raw lines of an export, it is used to parse an export
+// in parallel. It keeps the parser of the export the lines come from.
+type Batch struct {
+	parser  *Parser
+	name    string
+	data    []byte
+	ends    []int
+	numbers []int
+}
+
+// Returns the number of lines in the batch.
+func (b *Batch) Len() int {
+	return len(b.ends)
+}
+
+// Reads at most size lines without parsing them. It returns io.EOF once the
+// export is over and there are no lines left.
+func (r *Reader) ReadBatch(size int) (*Batch, error) {
+	parser, err := r.Parser()
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{
+		parser:  parser,
+		name:    r.name,
+		data:    make([]byte, 0, 64*size),
+		ends:    make([]int, 0, size),
+		numbers: make([]int, 0, size),
+	}
+	for batch.Len() < size {
+		line, lineNumber, ok := r.nextLine()
+		if !ok {
+			break
+		}
+		batch.data = append(batch.data, line...)
+		batch.ends = append(batch.ends, len(batch.data))
+		batch.numbers = append(batch.numbers, lineNumber)
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		r.err = err
+		return nil, err
+	}
+	if batch.Len() == 0 {
+		return nil, io.EOF
+	}
+	return batch, nil
+}
+
+// Parses the lines of the batch and calls fn for every record. The errors and
+// counts are recorded into the report. It is safe to parse different batches
+// concurrently.
+func (b *Batch) Parse(report *Report, fn func(record *Record)) {
+	start := 0
+	for i, end := range b.ends {
+		report.Lines++
+		record, perr := b.parser.ParseLine(b.data[start:end], b.numbers[i])
+		start = end
+		if perr != nil {
+			perr.File = b.name
+			report.Add(perr)
+			continue
+		}
+		report.Records++
+		record.File, record.Line = b.name, b.numbers[i]
+		fn(&record)
+	}
+}
@@ -0,0 +1,47 @@
+package nfp
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Fields is a bitmask of the optional columns present in a record.
+type Fields uint8
+
+const (
+	FieldRound Fields = 1 << iota
+	FieldTTL
+	FieldProbeProtocol
+	FieldTimestamp
+)
+
+// Record is a single near-far pair observed while probing a destination. Only
+// the three addresses are mandatory, the rest is set when the export has the
+// matching columns, see Has.
+type Record struct {
+	NearAddr      netip.Addr
+	FarAddr       netip.Addr
+	ProbeDstAddr  netip.Addr
+	Round         uint32
+	TTL           uint8
+	ProbeProtocol uint8
+	Timestamp     time.Time
+	Fields        Fields
+	// The file and the line the record was read from.
+	File string
+	Line int
+}
+
+// Reports whether the optional fields are all set.
+func (r *Record) Has(fields Fields) bool {
+	return r.Fields&fields == fields
+}
+
+// Returns the network of the probed destination with the prefix length of its
+// family.
+func (r *Record) ProbeDstPrefix(ipv4PrefixLength int, ipv6PrefixLength int) (netip.Prefix, error) {
+	if r.ProbeDstAddr.Is4() {
+		return r.ProbeDstAddr.Prefix(ipv4PrefixLength)
+	}
+	return r.ProbeDstAddr.Prefix(ipv6PrefixLength)
+}
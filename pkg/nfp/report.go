@@ -0,0 +1,109 @@
+package nfp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Maximum number of parse errors kept as samples in a report.
+const MaxErrorSamples = 100
+
+// ParseError describes why a line of the export was skipped.
+type ParseError struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *ParseError) Error() string {
+	location := fmt.Sprintf("line %v", e.Line)
+	if e.File != "" {
+		location = fmt.Sprintf("%v:%v", e.File, e.Line)
+	}
+	if e.Column == "" {
+		return fmt.Sprintf("%v: %v", location, e.Err)
+	}
+	return fmt.Sprintf("%v: column %v: invalid value %q: %v", location, e.Column, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) MarshalJSON() ([]byte, error) {
+	type alias ParseError
+	return json.Marshal(struct {
+		*alias
+		Message string `json:"error"`
+	}{
+		alias:   (*alias)(e),
+		Message: e.Err.Error(),
+	})
+}
+
+// Report summarizes the parsing of an export. Instead of logging every bad
+// line, the errors are counted per column and the first ones are kept.
+type Report struct {
+	Lines    int            `json:"lines"`
+	Records  int            `json:"records"`
+	Errors   int            `json:"errors"`
+	ByColumn map[string]int `json:"errors_by_column"`
+	Samples  []*ParseError  `json:"samples"`
+}
+
+// Creates a new empty report.
+func NewReport() *Report {
+	return &Report{
+		ByColumn: make(map[string]int),
+	}
+}
+
+// Records the parse error.
+func (r *Report) Add(err *ParseError) {
+	r.Errors++
+	column := err.Column
+	if column == "" {
+		column = "-"
+	}
+	r.ByColumn[column]++
+	if len(r.Samples) < MaxErrorSamples {
+		r.Samples = append(r.Samples, err)
+	}
+}
+
+// Adds the counts and samples of the other report into this one.
+func (r *Report) Merge(other *Report) {
+	r.Lines += other.Lines
+	r.Records += other.Records
+	r.Errors += other.Errors
+	for column, count := range other.ByColumn {
+		r.ByColumn[column] += count
+	}
+	for _, err := range other.Samples {
+		if len(r.Samples) >= MaxErrorSamples {
+			break
+		}
+		r.Samples = append(r.Samples, err)
+	}
+	sort.SliceStable(r.Samples, func(i, j int) bool {
+		if r.Samples[i].File != r.Samples[j].File {
+			return r.Samples[i].File < r.Samples[j].File
+		}
+		return r.Samples[i].Line < r.Samples[j].Line
+	})
+}
+
+// Writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("lines=%v, records=%v, errors=%v", r.Lines, r.Records, r.Errors)
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// Maximum number of prefixes returned by a single prefix listing.
+const MaxPrefixLimit = 10000
+
+// Server exposes a loaded FIB over HTTP with JSON responses:
+//
+//	GET /health
+//	GET /stats
+//	GET /routers/{near}
+//	GET /routers/{near}/prefixes?offset=0&limit=1000
+//	GET /routers/{near}/lookup?dst=ADDR
+//	GET /routers/{near}/contains?prefix=PREFIX
+//
+// The FIB is only read, so the requests are served concurrently. Replace swaps
+// the served FIB without interrupting the requests in flight.
+type Server struct {
+	mu      sync.RWMutex
+	fib     *ds.FIB
+	summary ds.Summary
+	mux     *http.ServeMux
+}
+
+// Creates a new server of the given FIB.
+func New(f *ds.FIB) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.Replace(f)
+
+	s.mux.HandleFunc("GET /health", s.handleHealth)
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	s.mux.HandleFunc("GET /routers/{near}", s.handleRouter)
+	s.mux.HandleFunc("GET /routers/{near}/prefixes", s.handlePrefixes)
+	s.mux.HandleFunc("GET /routers/{near}/lookup", s.handleLookup)
+	s.mux.HandleFunc("GET /routers/{near}/contains", s.handleContains)
+	return s
+}
+
+// Replaces the served FIB.
+func (s *Server) Replace(f *ds.FIB) {
+	summary := f.Summary()
+	s.mu.Lock()
+	s.fib = f
+	s.summary = summary
+	s.mu.Unlock()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Returns the served FIB and its summary.
+func (s *Server) current() (*ds.FIB, ds.Summary) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fib, s.summary
+}
+
+// nextHops is a prefix with its next hops.
+type nextHops struct {
+	Prefix   netip.Prefix `json:"prefix"`
+	NextHops []netip.Addr `json:"next_hops"`
+}
+
+func newNextHops(prefix netip.Prefix, entry *ds.FTEntry) nextHops {
+	return nextHops{Prefix: prefix, NextHops: entry.Addrs()}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	_, summary := s.current()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "ok",
+		"routers": summary.Routers,
+	})
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	_, summary := s.current()
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Returns the forwarding table of the router in the path, or writes the error.
+func (s *Server) router(w http.ResponseWriter, r *http.Request) (netip.Addr, *ds.FT, bool) {
+	near, err := netip.ParseAddr(r.PathValue("near"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid router address")
+		return netip.Addr{}, nil, false
+	}
+	near = near.Unmap()
+
+	f, _ := s.current()
+	ft, found := f.GetAddr(near)
+	if !found {
+		writeError(w, http.StatusNotFound, "router not found")
+		return near, nil, false
+	}
+	return near, ft, true
+}
+
+func (s *Server) handleRouter(w http.ResponseWriter, r *http.Request) {
+	near, ft, ok := s.router(w, r)
+	if !ok {
+		return
+	}
+
+	nextHopSet := make(map[netip.Addr]struct{})
+	ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+		for _, addr := range entry.Addrs() {
+			nextHopSet[addr] = struct{}{}
+		}
+		return true
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"router":        near,
+		"num_prefixes":  ft.Len(),
+		"num_next_hops": len(nextHopSet),
+	})
+}
+
+func (s *Server) handlePrefixes(w http.ResponseWriter, r *http.Request) {
+	near, ft, ok := s.router(w, r)
+	if !ok {
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(r, "limit", 1000)
+	if err != nil || limit <= 0 || limit > MaxPrefixLimit {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	prefixes := make([]nextHops, 0, min(limit, ft.Len()))
+	i := 0
+	ft.Walk(func(prefix netip.Prefix, entry *ds.FTEntry) bool {
+		if i >= offset {
+			prefixes = append(prefixes, newNextHops(prefix, entry))
+		}
+		i++
+		return len(prefixes) < limit
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"router":   near,
+		"total":    ft.Len(),
+		"offset":   offset,
+		"prefixes": prefixes,
+	})
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	dst, err := netip.ParseAddr(r.URL.Query().Get("dst"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid destination address")
+		return
+	}
+	near, ft, ok := s.router(w, r)
+	if !ok {
+		return
+	}
+
+	prefix, entry, found := ft.LookupAddr(dst.Unmap())
+	if !found {
+		writeError(w, http.StatusNotFound, "no route to the destination")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"router": near,
+		"dst":    dst.Unmap(),
+		"route":  newNextHops(prefix, entry),
+	})
+}
+
+func (s *Server) handleContains(w http.ResponseWriter, r *http.Request) {
+	prefix, err := netip.ParsePrefix(r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid prefix")
+		return
+	}
+	near, ft, ok := s.router(w, r)
+	if !ok {
+		return
+	}
+
+	prefix = prefix.Masked()
+	entry, found := ft.ContainsPrefix(prefix)
+	if !found {
+		writeError(w, http.StatusNotFound, "prefix not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"router": near,
+		"route":  newNextHops(prefix, entry),
+	})
+}
+
+// Returns the integer query parameter or the default value if it is not set.
+func queryInt(r *http.Request, name string, value int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return value, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
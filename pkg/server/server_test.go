@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/ubombar/routeinfo/pkg/ds"
+)
+
+// Creates a test server of a FIB with two routers, the first one has 25
+// prefixes.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	f := ds.NewFIB(2, true, 24, 48)
+	near := netip.MustParseAddr("10.0.0.1")
+	for i := 0; i < 25; i++ {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{8, 8, byte(i), 0}), 24)
+		f.InsertPrefix(near, prefix, netip.MustParseAddr("10.0.0.2"))
+	}
+	f.InsertPrefix(near, netip.MustParsePrefix("8.8.0.0/24"), netip.MustParseAddr("10.0.0.3"))
+	f.InsertPrefix(netip.MustParseAddr("2001:db8::1"), netip.MustParsePrefix("2a00::/48"), netip.MustParseAddr("2001:db8::2"))
+
+	ts := httptest.NewServer(New(f))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// Performs the GET request and decodes the JSON response.
+func get(t *testing.T, ts *httptest.Server, path string) (int, map[string]any) {
+	t.Helper()
+	resp, err := ts.Client().Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %v: content type %q", path, ct)
+	}
+	body := make(map[string]any)
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("GET %v: %v", path, err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestHealthAndStats(t *testing.T) {
+	ts := newTestServer(t)
+
+	status, body := get(t, ts, "/health")
+	if status != http.StatusOK || body["status"] != "ok" || body["routers"] != 2.0 {
+		t.Errorf("/health = %v %v", status, body)
+	}
+	status, body = get(t, ts, "/stats")
+	if status != http.StatusOK || body["routers"] != 2.0 || body["prefixes"] != 26.0 || body["edges"] != 27.0 {
+		t.Errorf("/stats = %v %v", status, body)
+	}
+}
+
+func TestRouterEndpoints(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		path   string
+		status int
+		check  func(body map[string]any) bool
+	}{
+		{"/routers/10.0.0.1", http.StatusOK, func(body map[string]any) bool {
+			return body["num_prefixes"] == 25.0 && body["num_next_hops"] == 2.0
+		}},
+		{"/routers/::ffff:10.0.0.1", http.StatusOK, func(body map[string]any) bool {
+			return body["router"] == "10.0.0.1"
+		}},
+		{"/routers/10.0.0.1/lookup?dst=8.8.0.9", http.StatusOK, func(body map[string]any) bool {
+			route := body["route"].(map[string]any)
+			return route["prefix"] == "8.8.0.0/24" && fmt.Sprint(route["next_hops"]) == "[10.0.0.2 10.0.0.3]"
+		}},
+		{"/routers/2001:db8::1/lookup?dst=2a00::1", http.StatusOK, func(body map[string]any) bool {
+			return body["route"].(map[string]any)["prefix"] == "2a00::/48"
+		}},
+		{"/routers/10.0.0.1/lookup?dst=1.1.1.1", http.StatusNotFound, nil},
+		{"/routers/10.0.0.1/contains?prefix=8.8.3.7/24", http.StatusOK, func(body map[string]any) bool {
+			return body["route"].(map[string]any)["prefix"] == "8.8.3.0/24"
+		}},
+		{"/routers/10.0.0.1/contains?prefix=8.8.0.0/16", http.StatusNotFound, nil},
+
+		{"/routers/not-an-address", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/lookup?dst=bad", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/lookup", http.StatusBadRequest, nil},
+		{"/routers/bad/lookup?dst=8.8.8.8", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/contains?prefix=8.8.8.8", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/prefixes?offset=-1", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/prefixes?limit=0", http.StatusBadRequest, nil},
+		{"/routers/10.0.0.1/prefixes?limit=x", http.StatusBadRequest, nil},
+
+		{"/routers/10.9.9.9", http.StatusNotFound, nil},
+		{"/routers/10.9.9.9/prefixes", http.StatusNotFound, nil},
+		{"/routers/10.9.9.9/lookup?dst=8.8.8.8", http.StatusNotFound, nil},
+		{"/routers/10.9.9.9/contains?prefix=8.8.8.0/24", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		status, body := get(t, ts, tt.path)
+		if status != tt.status {
+			t.Errorf("GET %v = %v %v, want %v", tt.path, status, body, tt.status)
+			continue
+		}
+		if status != http.StatusOK {
+			if _, found := body["error"]; !found {
+				t.Errorf("GET %v: no error message in %v", tt.path, body)
+			}
+			continue
+		}
+		if !tt.check(body) {
+			t.Errorf("GET %v: unexpected body %v", tt.path, body)
+		}
+	}
+}
+
+func TestPrefixesPaging(t *testing.T) {
+	ts := newTestServer(t)
+
+	var prefixes []string
+	for offset := 0; ; offset += 10 {
+		status, body := get(t, ts, fmt.Sprintf("/routers/10.0.0.1/prefixes?offset=%v&limit=10", offset))
+		if status != http.StatusOK || body["total"] != 25.0 || body["offset"] != float64(offset) {
+			t.Fatalf("offset %v: %v %v", offset, status, body)
+		}
+		page := body["prefixes"].([]any)
+		for _, p := range page {
+			prefixes = append(prefixes, p.(map[string]any)["prefix"].(string))
+		}
+		if len(page) < 10 {
+			break
+		}
+	}
+
+	if len(prefixes) != 25 {
+		t.Fatalf("got %v prefixes over the pages, want 25", len(prefixes))
+	}
+	for i, prefix := range prefixes {
+		if want := fmt.Sprintf("8.8.%v.0/24", i); prefix != want {
+			t.Errorf("prefix %v = %v, want %v", i, prefix, want)
+		}
+	}
+
+	status, body := get(t, ts, "/routers/10.0.0.1/prefixes?offset=100")
+	if status != http.StatusOK || len(body["prefixes"].([]any)) != 0 {
+		t.Errorf("offset past the end: %v %v", status, body)
+	}
+}